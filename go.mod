@@ -3,6 +3,7 @@ module github.com/libatomic/litmus
 go 1.14
 
 require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/stretchr/testify v1.6.1
-	github.com/tj/assert v0.0.3
+	gopkg.in/yaml.v3 v3.0.0-20200605160147-a5ece683394c
 )
@@ -0,0 +1,74 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"encoding/xml"
+)
+
+type (
+	anyOf []interface{}
+
+	// probeT records comparison failures without failing the test
+	probeT struct {
+		failed bool
+	}
+)
+
+// AnyOf returns an expected response that passes if the body matches any of the candidates
+func AnyOf(candidates ...interface{}) interface{} {
+	return anyOf(candidates)
+}
+
+func (p *probeT) Errorf(format string, args ...interface{}) { p.failed = true }
+func (p *probeT) Fatalf(format string, args ...interface{}) { p.failed = true }
+func (p *probeT) FailNow()                                  { p.failed = true }
+func (p *probeT) Helper()                                   {}
+func (p *probeT) Logf(format string, args ...interface{})   {}
+
+// assertAnyOf compares the response body with each candidate until one matches
+func (t *Test) assertAnyOf(tt testingT, candidates anyOf, data []byte, isXML bool) {
+	tt.Helper()
+
+	marshal := json.Marshal
+	if isXML {
+		marshal = xml.Marshal
+	}
+
+	for _, c := range candidates {
+		var expected string
+
+		switch m := c.(type) {
+		case []byte:
+			expected = string(m)
+		case string:
+			expected = m
+		default:
+			b, err := marshal(m)
+			if err != nil {
+				tt.Fatalf("%s", marshalError("ExpectedResponse", m, err).Error())
+			}
+			expected = string(b)
+		}
+
+		p := &probeT{}
+		if isXML {
+			xmlEq(p, expected, string(data))
+		} else {
+			t.jsonEq(p, expected, string(data))
+		}
+
+		if !p.failed {
+			return
+		}
+	}
+
+	tt.Errorf("response did not match any of %d expected bodies: %s", len(candidates), string(data))
+}
@@ -0,0 +1,37 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAnyOf(t *testing.T) {
+	a := &user{ID: "u1", Name: "Ann"}
+	b := &user{ID: "u1", Name: "Annie"}
+
+	test := Test{
+		Method:           http.MethodGet,
+		Path:             "/users/u1",
+		ExpectedStatus:   http.StatusOK,
+		ExpectedResponse: AnyOf(a, b),
+	}
+
+	runCases(t, stateless(jsonHandler(http.StatusOK, a)), []testCase{
+		{name: "first", test: test},
+		{name: "second", test: test, handler: stateless(jsonHandler(http.StatusOK, b))},
+		{
+			name:    "none",
+			test:    test,
+			handler: stateless(jsonHandler(http.StatusOK, &user{ID: "u1", Name: "Bob"})),
+			failure: "response did not match any of 2 expected bodies",
+		},
+	})
+}
@@ -0,0 +1,48 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ArgAs returns the arg of the call to the backend method, panicking if it is missing or not a T; the backend
+// must be a Mock, a mock.Mock or embed Mock to record its calls
+func ArgAs[T any](backend Backend, method string, call, arg int) T {
+	recorded, ok := recordedCalls(backend)
+	if !ok {
+		panic(fmt.Sprintf("litmus: ArgAs(%s, %d, %d) failed: %T does not record calls", method, call, arg, backend))
+	}
+
+	calls := make([][]interface{}, 0)
+	for _, c := range recorded {
+		if c.Method == method {
+			calls = append(calls, c.Arguments)
+		}
+	}
+
+	if call >= len(calls) {
+		panic(fmt.Sprintf("litmus: ArgAs(%s, %d, %d) failed: %d calls recorded", method, call, arg, len(calls)))
+	}
+
+	if arg >= len(calls[call]) {
+		panic(fmt.Sprintf("litmus: ArgAs(%s, %d, %d) failed: %d args recorded", method, call, arg, len(calls[call])))
+	}
+
+	v, ok := calls[call][arg].(T)
+	if !ok {
+		panic(fmt.Sprintf("litmus: ArgAs(%s, %d, %d) failed: %T is not %s", method, call, arg, calls[call][arg], reflect.TypeOf((*T)(nil)).Elem()))
+	}
+
+	return v
+}
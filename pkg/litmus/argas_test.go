@@ -0,0 +1,116 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestArgAs(t *testing.T) {
+	store := &userStore{}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var u user
+		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		store.Audit("create " + u.ID)
+		if err := store.Save(&u); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	test := &Test{
+		Operations: []Operation{
+			{Name: "Audit", Args: Args{""}},
+			{Name: "Save", Args: Args{&user{}}, Returns: Returns{nil}},
+		},
+		Method:         http.MethodPost,
+		Path:           "/users",
+		Request:        user{ID: "u1", Name: "ada", Address: address{City: "London"}},
+		ExpectedStatus: http.StatusCreated,
+	}
+
+	test.Do(store, handler, t)
+
+	wrapped := &wrappedStore{}
+	wrapped.On("Get", "u2").Return(&user{ID: "u2"}, nil)
+	wrapped.Get("u2")
+
+	cases := []struct {
+		name     string
+		arg      func() interface{}
+		expected interface{}
+		panic    string
+	}{
+		{
+			name:     "store",
+			arg:      func() interface{} { return ArgAs[*user](store, "Save", 0, 0).Address.City },
+			expected: "London",
+		},
+		{
+			name:     "mock",
+			arg:      func() interface{} { return ArgAs[string](&store.Mock, "Audit", 0, 0) },
+			expected: "create u1",
+		},
+		{
+			name:     "testify mock",
+			arg:      func() interface{} { return ArgAs[string](&wrapped.Mock, "Get", 0, 0) },
+			expected: "u2",
+		},
+		{
+			name:  "call",
+			arg:   func() interface{} { return ArgAs[*user](store, "Save", 1, 0) },
+			panic: "litmus: ArgAs(Save, 1, 0) failed: 1 calls recorded",
+		},
+		{
+			name:  "arg",
+			arg:   func() interface{} { return ArgAs[*user](store, "Save", 0, 1) },
+			panic: "litmus: ArgAs(Save, 0, 1) failed: 1 args recorded",
+		},
+		{
+			name:  "type",
+			arg:   func() interface{} { return ArgAs[user](store, "Save", 0, 0) },
+			panic: "litmus: ArgAs(Save, 0, 0) failed: *litmus.user is not litmus.user",
+		},
+		{
+			name:  "not recorded",
+			arg:   func() interface{} { return ArgAs[string](wrapped, "Get", 0, 0) },
+			panic: "litmus: ArgAs(Get, 0, 0) failed: *litmus.wrappedStore does not record calls",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); (r != nil || c.panic != "") && r != c.panic {
+					t.Errorf("unexpected panic %v, expected %q", r, c.panic)
+				}
+			}()
+
+			if v := c.arg(); v != c.expected {
+				t.Errorf("unexpected arg %v, expected %v", v, c.expected)
+			}
+		})
+	}
+
+	if recorded := test.Operations[1].Recorded(); len(recorded) != 1 || recorded[0][0] != ArgAs[*user](store, "Save", 0, 0) {
+		t.Errorf("unexpected recorded Save calls %v", recorded)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type (
+	// wrappedStore is a backend built on the testify mock instead of Mock, with helpers of its own
+	wrappedStore struct {
+		mock.Mock
+	}
+)
+
+func (s *wrappedStore) Get(id string) (*user, error) {
+	r := s.Called(id)
+	u, _ := r.Get(0).(*user)
+	return u, r.Error(1)
+}
+
+// gets returns the ids of the recorded Get calls
+func (s *wrappedStore) gets() []string {
+	ids := make([]string, 0)
+	for _, c := range s.Calls {
+		if c.Method == "Get" {
+			ids = append(ids, c.Arguments.String(0))
+		}
+	}
+	return ids
+}
+
+func TestCustomBackend(t *testing.T) {
+	handler := func(store *wrappedStore, id string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if id == "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			u, err := store.Get(id)
+			if err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(u)
+		})
+	}
+
+	cases := []struct {
+		name    string
+		test    Test
+		id      string
+		failure string
+	}{
+		{
+			name: "get",
+			test: Test{
+				Operations: []Operation{
+					{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1", Name: "ada"}, nil}},
+				},
+				Method:           http.MethodGet,
+				Path:             "/users/u1",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: OperationReturn(0),
+			},
+			id: "u1",
+		},
+		{
+			name: "unmet expectations",
+			test: Test{
+				Operations: []Operation{
+					{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1", Name: "ada"}, nil}},
+				},
+				Method:         http.MethodGet,
+				Path:           "/users/u1",
+				ExpectedStatus: http.StatusNotFound,
+			},
+			failure: "0 out of 1 expectation(s) were met",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			store := &wrappedStore{}
+
+			if c.failure != "" {
+				requireFailure(t, run(&c.test, store, handler(store, c.id)), c.failure)
+				return
+			}
+
+			c.test.Do(store, handler(store, c.id), t)
+
+			if ids := store.gets(); len(ids) != 1 || ids[0] != c.id {
+				t.Errorf("unexpected Get calls %v", ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"text/template"
+)
+
+// Template returns a request handler that renders the text/template with the data as the request body
+func Template(tmpl string, data interface{}) RequestHandler {
+	return func(backend interface{}, t *Test) (io.Reader, error) {
+		tpl, err := template.New("request").Parse(tmpl)
+		if err != nil {
+			return nil, err
+		}
+
+		buf := &bytes.Buffer{}
+		if err := tpl.Execute(buf, data); err != nil {
+			return nil, err
+		}
+
+		return buf, nil
+	}
+}
+
+// Repeat returns a request handler that sends a body of n copies of the byte
+func Repeat(b byte, n int) RequestHandler {
+	return func(backend interface{}, t *Test) (io.Reader, error) {
+		return bytes.NewReader(bytes.Repeat([]byte{b}, n)), nil
+	}
+}
+
+// RandomBody returns a request handler that sends n pseudo-random bytes, seeded by n so runs are repeatable
+func RandomBody(n int) RequestHandler {
+	return func(backend interface{}, t *Test) (io.Reader, error) {
+		buf := make([]byte, n)
+		rand.New(rand.NewSource(int64(n))).Read(buf)
+
+		return bytes.NewReader(buf), nil
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var u user
+		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(u)
+	})
+
+	template := func(tmpl string) Test {
+		return Test{
+			Method:             http.MethodPost,
+			Path:               "/users",
+			Request:            Template(tmpl, map[string]string{"ID": "u1", "City": "Paris"}),
+			RequestContentType: "application/json",
+			ExpectedStatus:     http.StatusOK,
+			ExpectedResponse:   &user{ID: "u1", Address: address{City: "Paris"}},
+		}
+	}
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "template",
+			test: template(`{"ID":"{{.ID}}","Address":{"City":"{{.City}}"}}`),
+		},
+		{
+			name:    "invalid template",
+			test:    template(`{"ID":"{{.ID}"}`),
+			failure: "failed to build request body",
+		},
+	})
+}
+
+func TestRepeat(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if len(bytes.Trim(data, "a")) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	upload := func(body RequestHandler, n int64, status int) Test {
+		return Test{
+			Method:                       http.MethodPost,
+			Path:                         "/upload",
+			Request:                      body,
+			RequestContentType:           "application/octet-stream",
+			ExpectedStatus:               status,
+			ExpectedRequestContentLength: n,
+		}
+	}
+
+	runCases(t, stateless(handler), []testCase{
+		{name: "too large", test: upload(Repeat('a', 2<<20), 2<<20, http.StatusRequestEntityTooLarge)},
+		{name: "limit", test: upload(Repeat('a', 1<<20), 1<<20, http.StatusNoContent)},
+		{name: "random", test: upload(RandomBody(1024), 1024, http.StatusBadRequest)},
+	})
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type (
+	// OperationBuilder fluently builds a slice of operations
+	OperationBuilder struct {
+		ops []Operation
+	}
+)
+
+// Ops returns a new operation builder
+func Ops() *OperationBuilder {
+	return &OperationBuilder{
+		ops: make([]Operation, 0),
+	}
+}
+
+// Call adds an operation, args may include testify matchers
+func (b *OperationBuilder) Call(name string, args ...interface{}) *OperationBuilder {
+	b.ops = append(b.ops, Operation{
+		Name: name,
+		Args: args,
+	})
+	return b
+}
+
+// Return sets the returns of the last operation
+func (b *OperationBuilder) Return(returns ...interface{}) *OperationBuilder {
+	b.last().Returns = returns
+	return b
+}
+
+// ReturnStack sets the return stack of the last operation
+func (b *OperationBuilder) ReturnStack(returns ...[]interface{}) *OperationBuilder {
+	b.last().ReturnStack = returns
+	return b
+}
+
+// ReturnMap sets the returns of the last operation for calls with the ArgKey
+func (b *OperationBuilder) ReturnMap(key string, returns ...interface{}) *OperationBuilder {
+	o := b.last()
+	if o.ReturnMap == nil {
+		o.ReturnMap = make(map[string][]interface{})
+	}
+	o.ReturnMap[key] = returns
+	return b
+}
+
+// Optional allows the last operation to not be called
+func (b *OperationBuilder) Optional() *OperationBuilder {
+	b.last().Optional = true
+	return b
+}
+
+// Times sets the exact number of calls expected for the last operation
+func (b *OperationBuilder) Times(n int) *OperationBuilder {
+	b.last().Times = n
+	return b
+}
+
+// AtLeast sets the minimum number of calls expected for the last operation
+func (b *OperationBuilder) AtLeast(n int) *OperationBuilder {
+	b.last().MinTimes = n
+	return b
+}
+
+// AtMost sets the maximum number of calls expected for the last operation
+func (b *OperationBuilder) AtMost(n int) *OperationBuilder {
+	b.last().MaxTimes = n
+	return b
+}
+
+// Delay sets the delay of the last operation
+func (b *OperationBuilder) Delay(d time.Duration) *OperationBuilder {
+	b.last().Delay = d
+	return b
+}
+
+// WaitFor blocks the last operation until the channel receives or is closed
+func (b *OperationBuilder) WaitFor(ch <-chan time.Time) *OperationBuilder {
+	b.last().WaitFor = ch
+	return b
+}
+
+// Backend sets the backend of the last operation
+func (b *OperationBuilder) Backend(m *mock.Mock) *OperationBuilder {
+	b.last().Backend = m
+	return b
+}
+
+// Operations returns the built operations
+func (b *OperationBuilder) Operations() []Operation {
+	return b.ops
+}
+
+func (b *OperationBuilder) last() *Operation {
+	if len(b.ops) == 0 {
+		panic("litmus: Call must be used before setting operation options")
+	}
+	return &b.ops[len(b.ops)-1]
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOperationBuilder(t *testing.T) {
+	handler := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gets, _ := strconv.Atoi(r.URL.Query().Get("gets"))
+
+			var u *user
+			for i := 0; i < gets; i++ {
+				u, _ = store.Get("u1")
+			}
+			store.Save(u)
+			store.Audit("saved u1")
+
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+
+	ops := func() []Operation {
+		return Ops().
+			Call("Get", "u1").Return(&user{ID: "u1"}, nil).Times(2).
+			Call("Save", mock.MatchedBy(func(u *user) bool { return u.ID == "u1" })).Return(nil).
+			Call("Audit", mock.MatchedBy(func(msg string) bool { return strings.HasPrefix(msg, "saved") })).
+			Operations()
+	}
+
+	if n := len(ops()); n != 3 {
+		t.Fatalf("built %d operations, expected 3", n)
+	}
+
+	runCases(t, handler, []testCase{
+		{
+			name: "built",
+			test: Test{
+				Operations:     ops(),
+				Method:         http.MethodPut,
+				Path:           "/users/u1",
+				Query:          url.Values{"gets": []string{"2"}},
+				ExpectedStatus: http.StatusNoContent,
+			},
+		},
+		{
+			name: "times",
+			test: Test{
+				Operations:     ops(),
+				Method:         http.MethodPut,
+				Path:           "/users/u1",
+				Query:          url.Values{"gets": []string{"1"}},
+				ExpectedStatus: http.StatusNoContent,
+			},
+			failure: "2 out of 3 expectation(s) were met",
+		},
+	})
+}
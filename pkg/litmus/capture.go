@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type (
+	// ContextCapture records the context an operation was called with
+	ContextCapture struct {
+		ctx  context.Context
+		at   time.Time
+		lock sync.Mutex
+	}
+)
+
+// CaptureContext returns a new context capture, use Matcher as the operation context arg
+func CaptureContext() *ContextCapture {
+	return &ContextCapture{}
+}
+
+// Matcher returns an operation arg matcher that records the context
+func (c *ContextCapture) Matcher() interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		c.ctx = ctx
+		c.at = time.Now()
+
+		return true
+	})
+}
+
+// Context returns the captured context or nil if the operation was not called
+func (c *ContextCapture) Context() context.Context {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.ctx
+}
+
+// AssertDeadline asserts the captured context had a deadline between min and max from the time of the call
+func (c *ContextCapture) AssertDeadline(tt *testing.T, min, max time.Duration) {
+	tt.Helper()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.ctx == nil {
+		tt.Fatalf("context was not captured")
+	}
+
+	deadline, ok := c.ctx.Deadline()
+	if !ok {
+		tt.Fatalf("captured context has no deadline")
+	}
+
+	if d := deadline.Sub(c.at); d < min || d > max {
+		tt.Fatalf("captured context deadline %s not within [%s, %s]", d, min, max)
+	}
+}
+
+// AssertCanceled asserts the captured context was canceled
+func (c *ContextCapture) AssertCanceled(tt *testing.T) {
+	tt.Helper()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.ctx == nil {
+		tt.Fatalf("context was not captured")
+	}
+
+	if err := c.ctx.Err(); err != context.Canceled {
+		tt.Fatalf("captured context not canceled: %v", err)
+	}
+}
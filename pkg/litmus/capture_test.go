@@ -0,0 +1,69 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContextCaptureDeadline(t *testing.T) {
+	handler := func(store *userStore, timeout time.Duration) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			store.Fetch(ctx, "u1")
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+
+	cases := []struct {
+		name     string
+		timeout  time.Duration
+		min, max time.Duration
+		failure  string
+	}{
+		{name: "deadline", timeout: 2 * time.Second, min: 1900 * time.Millisecond, max: 2 * time.Second},
+		{name: "no deadline", max: 2 * time.Second, failure: "captured context has no deadline"},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			// the failing assertions run in a child process
+			if c.failure != "" && !inSubprocess(t) {
+				if out := subprocess(t); !strings.Contains(out, c.failure) {
+					t.Errorf("expected %q to be reported:\n%s", c.failure, out)
+				}
+				return
+			}
+
+			store := &userStore{}
+			capture := CaptureContext()
+
+			(&Test{
+				Operations:     []Operation{{Name: "Fetch", Args: Args{capture.Matcher(), "u1"}, Returns: Returns{nil}}},
+				Method:         http.MethodGet,
+				Path:           "/users/u1",
+				ExpectedStatus: http.StatusNoContent,
+			}).Do(&store.Mock, handler(store, c.timeout), t)
+
+			capture.AssertDeadline(t, c.min, c.max)
+		})
+	}
+}
@@ -0,0 +1,605 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/stretchr/testify/require"
+)
+
+// isJSONContentType returns true for json and +json media types
+func isJSONContentType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// validJSON checks the data is a single well-formed json value
+func validJSON(data []byte) error {
+	var v interface{}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		if serr, ok := err.(*json.SyntaxError); ok {
+			return fmt.Errorf("%s at offset %d", serr.Error(), serr.Offset)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// jsonEq asserts the json documents are equivalent using the test comparison options
+func (t *Test) jsonEq(tt testingT, expected, actual string) {
+	tt.Helper()
+
+	if t.StrictKeyOrder {
+		t.keyOrderEq(tt, expected, actual)
+	}
+
+	if !t.normalizes() {
+		require.JSONEq(tt, expected, actual, jsonDiffMessage(expected, actual))
+		return
+	}
+
+	e, err := decodeJSON(expected, t.StrictNumbers)
+	if err != nil {
+		tt.Fatalf("expected value is not valid json: %s", err.Error())
+	}
+
+	a, err := decodeJSON(actual, t.StrictNumbers)
+	if err != nil {
+		tt.Fatalf("response is not valid json: %s", err.Error())
+	}
+
+	e, a = t.normalizeJSON(e, ""), t.normalizeJSON(a, "")
+
+	if t.FloatTolerance > 0 {
+		a = t.snapNumbers(e, a)
+	}
+
+	require.Equal(tt, e, a, formatJSONDiff(jsonDiff(e, a, "$")))
+}
+
+// assertContainsValues asserts each ExpectedContainsValue is found at any depth of the json document
+func (t *Test) assertContainsValues(tt testingT, body []byte) {
+	tt.Helper()
+
+	doc, err := decodeJSON(string(body), false)
+	if err != nil {
+		tt.Fatalf("response is not valid json: %s", err.Error())
+	}
+
+	for _, v := range t.ExpectedContainsValue {
+		data, err := json.Marshal(v)
+		if err != nil {
+			tt.Fatalf("%s", marshalError("ExpectedContainsValue", v, err).Error())
+		}
+
+		want, err := decodeJSON(string(data), false)
+		if err != nil {
+			tt.Fatalf("failed to decode expected value: %s", err.Error())
+		}
+
+		if !containsValue(doc, want) {
+			tt.Errorf("response does not contain value %s", string(data))
+		}
+	}
+}
+
+// containsValue returns true if the value equals v or any value nested in it
+func containsValue(v, want interface{}) bool {
+	if reflect.DeepEqual(v, want) {
+		return true
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, e := range val {
+			if containsValue(e, want) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, e := range val {
+			if containsValue(e, want) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// keyOrderEq asserts the objects of the json documents have the same key order
+func (t *Test) keyOrderEq(tt testingT, expected, actual string) {
+	tt.Helper()
+
+	e, err := keyOrder(expected)
+	if err != nil {
+		tt.Fatalf("expected value is not valid json: %s", err.Error())
+	}
+
+	a, err := keyOrder(actual)
+	if err != nil {
+		tt.Fatalf("response is not valid json: %s", err.Error())
+	}
+
+	require.Equal(tt, e, a, "unexpected json key order")
+}
+
+// keyOrder returns the keys of each object in the document in order, prefixed by the object path
+func keyOrder(doc string) ([]string, error) {
+	dec := json.NewDecoder(strings.NewReader(doc))
+	dec.UseNumber()
+
+	order := make([]string, 0)
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			keys := make([]string, 0)
+			for dec.More() {
+				k, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key := k.(string)
+				keys = append(keys, key)
+				if err := walk(path + "." + key); err != nil {
+					return err
+				}
+			}
+			order = append(order, path+": "+strings.Join(keys, ","))
+			_, err = dec.Token()
+			return err
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+			return err
+		}
+
+		return nil
+	}
+
+	if err := walk("$"); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// jsonDiffMessage returns the formatted diff of the documents, or empty if either is invalid
+func jsonDiffMessage(expected, actual string) string {
+	e, err := decodeJSON(expected, false)
+	if err != nil {
+		return ""
+	}
+
+	a, err := decodeJSON(actual, false)
+	if err != nil {
+		return ""
+	}
+
+	return formatJSONDiff(jsonDiff(e, a, "$"))
+}
+
+// jsonDiff returns the paths that differ between the decoded values
+func jsonDiff(expected, actual interface{}, path string) []string {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(e)+len(a))
+		for k := range e {
+			keys = append(keys, k)
+		}
+		for k := range a {
+			if _, ok := e[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		diff := make([]string, 0)
+		for _, k := range keys {
+			ev, eok := e[k]
+			av, aok := a[k]
+
+			switch {
+			case !aok:
+				diff = append(diff, fmt.Sprintf("%s.%s: missing, expected %s", path, k, canonicalJSON(ev)))
+			case !eok:
+				diff = append(diff, fmt.Sprintf("%s.%s: unexpected %s", path, k, canonicalJSON(av)))
+			default:
+				diff = append(diff, jsonDiff(ev, av, path+"."+k)...)
+			}
+		}
+		return diff
+
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			break
+		}
+
+		diff := make([]string, 0)
+		if len(e) != len(a) {
+			diff = append(diff, fmt.Sprintf("%s: expected length %d, got %d", path, len(e), len(a)))
+		}
+
+		for i := 0; i < len(e) && i < len(a); i++ {
+			diff = append(diff, jsonDiff(e[i], a[i], fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return diff
+	}
+
+	if reflect.DeepEqual(expected, actual) {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("%s: expected %s, got %s", path, canonicalJSON(expected), canonicalJSON(actual))}
+}
+
+// formatJSONDiff formats the diff paths for a failure message
+func formatJSONDiff(diff []string) string {
+	if len(diff) == 0 {
+		return ""
+	}
+	return "json diff:\n\t" + strings.Join(diff, "\n\t")
+}
+
+// normalizes returns true if any option requires decoding the documents before comparison
+func (t *Test) normalizes() bool {
+	return t.StrictNumbers ||
+		t.UnorderedArrays ||
+		len(t.UnorderedPaths) > 0 ||
+		len(t.IgnoreArrayFields) > 0 ||
+		len(t.StringNumberPaths) > 0 ||
+		t.FloatTolerance > 0
+}
+
+// decodeJSON decodes the document, optionally preserving numbers as json.Number
+func decodeJSON(doc string, useNumber bool) (interface{}, error) {
+	var v interface{}
+
+	dec := json.NewDecoder(strings.NewReader(doc))
+	if useNumber {
+		dec.UseNumber()
+	}
+
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// normalizeJSON applies the comparison options to the decoded value at path
+func (t *Test) normalizeJSON(v interface{}, path string) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, e := range val {
+			val[k] = t.normalizeJSON(e, joinPath(path, k))
+		}
+
+	case []interface{}:
+		for i, e := range val {
+			if obj, ok := e.(map[string]interface{}); ok {
+				for _, f := range t.IgnoreArrayFields {
+					delete(obj, f)
+				}
+			}
+			val[i] = t.normalizeJSON(e, path)
+		}
+
+		if t.unordered(path) {
+			sort.SliceStable(val, func(i, j int) bool {
+				return canonicalJSON(val[i]) < canonicalJSON(val[j])
+			})
+		}
+
+	case string:
+		if t.stringNumber(path) {
+			if f, err := strconv.ParseFloat(val, 64); err == nil {
+				if t.StrictNumbers {
+					return json.Number(val)
+				}
+				return f
+			}
+		}
+	}
+
+	return v
+}
+
+// stringNumber returns true if numeric strings at path are compared as numbers
+func (t *Test) stringNumber(path string) bool {
+	for _, p := range t.StringNumberPaths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// snapNumbers returns the actual value with numbers within FloatTolerance of the expected replaced by the expected
+func (t *Test) snapNumbers(expected, actual interface{}) interface{} {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		if a, ok := actual.(map[string]interface{}); ok {
+			for k, v := range a {
+				if ev, ok := e[k]; ok {
+					a[k] = t.snapNumbers(ev, v)
+				}
+			}
+		}
+	case []interface{}:
+		if a, ok := actual.([]interface{}); ok {
+			for i := range a {
+				if i < len(e) {
+					a[i] = t.snapNumbers(e[i], a[i])
+				}
+			}
+		}
+	default:
+		ef, eok := jsonFloat(expected)
+		af, aok := jsonFloat(actual)
+		if eok && aok && math.Abs(ef-af) <= t.FloatTolerance {
+			return expected
+		}
+	}
+
+	return actual
+}
+
+// jsonFloat returns the float value of a decoded json number
+func jsonFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// unordered returns true if the array at path is compared as a multiset
+func (t *Test) unordered(path string) bool {
+	if t.UnorderedArrays {
+		return true
+	}
+
+	for _, p := range t.UnorderedPaths {
+		if p == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// canonicalJSON encodes the value with sorted object keys
+func canonicalJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// joinPath appends the key to the dotted path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// lookupPath returns the value at the dotted path, array elements are referenced by index
+func lookupPath(v interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return v, true
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch n := v.(type) {
+		case map[string]interface{}:
+			val, ok := n[key]
+			if !ok {
+				return nil, false
+			}
+			v = val
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, false
+			}
+			v = n[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return v, true
+}
+
+// assertFieldChanges compares the AssertChanged and AssertUnchanged paths of the request and response
+func (t *Test) assertFieldChanges(tt testingT, request, response []byte) {
+	tt.Helper()
+
+	req, err := decodeJSON(string(request), true)
+	if err != nil {
+		tt.Fatalf("failed to decode request body: %s", err.Error())
+	}
+
+	resp, err := decodeJSON(string(response), true)
+	if err != nil {
+		tt.Fatalf("failed to decode response body: %s", err.Error())
+	}
+
+	for _, p := range t.AssertChanged {
+		a, ok := lookupPath(req, p)
+		if !ok {
+			tt.Errorf("field %s not found in request", p)
+			continue
+		}
+
+		if b, ok := lookupPath(resp, p); ok && reflect.DeepEqual(a, b) {
+			tt.Errorf("field %s is unchanged in response: %s", p, canonicalJSON(b))
+		}
+	}
+
+	for _, p := range t.AssertUnchanged {
+		a, ok := lookupPath(req, p)
+		if !ok {
+			tt.Errorf("field %s not found in request", p)
+			continue
+		}
+
+		b, ok := lookupPath(resp, p)
+		if !ok {
+			tt.Errorf("field %s not found in response", p)
+			continue
+		}
+
+		if !reflect.DeepEqual(a, b) {
+			tt.Errorf("field %s changed in response: expected %s, actual %s", p, canonicalJSON(a), canonicalJSON(b))
+		}
+	}
+}
+
+// isTextContentType returns true for textual media types
+func isTextContentType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mt, "text/"),
+		isJSONContentType(ct),
+		isXMLContentType(ct),
+		mt == "application/x-www-form-urlencoded",
+		mt == "application/javascript":
+		return true
+	}
+
+	return false
+}
+
+// binaryEq asserts the byte slices are equal, reporting the first differing offset
+func binaryEq(tt testingT, expected, actual []byte) {
+	tt.Helper()
+
+	if bytes.Equal(expected, actual) {
+		return
+	}
+
+	i := 0
+	for i < len(expected) && i < len(actual) && expected[i] == actual[i] {
+		i++
+	}
+
+	window := func(b []byte) []byte {
+		end := i + 16
+		if end > len(b) {
+			end = len(b)
+		}
+		return b[i:end]
+	}
+
+	tt.Fatalf("binary response mismatch: expected %d bytes, got %d bytes, first difference at offset %d\n\texpected: % x\n\tactual:   % x",
+		len(expected), len(actual), i, window(expected), window(actual))
+}
+
+// isXMLContentType returns true for xml and +xml media types
+func isXMLContentType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mt == "application/xml" || mt == "text/xml" || strings.HasSuffix(mt, "+xml")
+}
+
+// xmlEq asserts the xml documents are equivalent ignoring insignificant whitespace
+func xmlEq(tt testingT, expected, actual string) {
+	tt.Helper()
+
+	e, err := canonicalXML(expected)
+	if err != nil {
+		tt.Fatalf("expected value is not valid xml: %s", err.Error())
+	}
+
+	a, err := canonicalXML(actual)
+	if err != nil {
+		tt.Fatalf("response is not valid xml: %s", err.Error())
+	}
+
+	require.Equal(tt, e, a)
+}
+
+// canonicalXML re-encodes the document dropping whitespace only character data
+func canonicalXML(doc string) (string, error) {
+	var buf bytes.Buffer
+
+	dec := xml.NewDecoder(strings.NewReader(doc))
+	enc := xml.NewEncoder(&buf)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+
+		switch v := tok.(type) {
+		case xml.CharData:
+			if len(bytes.TrimSpace(v)) == 0 {
+				continue
+			}
+		case xml.ProcInst, xml.Comment:
+			continue
+		}
+
+		if err := enc.EncodeToken(tok); err != nil {
+			return "", err
+		}
+	}
+
+	if err := enc.Flush(); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
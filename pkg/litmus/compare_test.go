@@ -0,0 +1,442 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRequireValidJSON(t *testing.T) {
+	body := func(contentType, body string) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Write([]byte(body))
+		}))
+	}
+
+	test := Test{Method: http.MethodGet, Path: "/users", ExpectedStatus: http.StatusOK, RequireValidJSON: true}
+
+	runCases(t, nil, []testCase{
+		{
+			name:    "valid",
+			test:    test,
+			handler: body("application/json", `{"users": []}`),
+		},
+		{
+			// only json content types are checked
+			name:    "text",
+			test:    test,
+			handler: body("text/plain", `{"users": [`),
+		},
+		{
+			name:    "truncated",
+			test:    test,
+			handler: body("application/json", `{"users": [`),
+			failure: "invalid json response: unexpected end of JSON input",
+		},
+		{
+			name:    "trailing data",
+			test:    test,
+			handler: body("application/problem+json", `{"users": []}x`),
+			failure: "invalid json response: invalid character 'x' after top-level value at offset",
+		},
+	})
+}
+
+func TestStrictNumbers(t *testing.T) {
+	raw := func(body string) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}))
+	}
+
+	numbers := func(strict bool, expected interface{}) Test {
+		return Test{
+			Method:           http.MethodGet,
+			Path:             "/items/1",
+			ExpectedStatus:   http.StatusOK,
+			ExpectedResponse: expected,
+			StrictNumbers:    strict,
+		}
+	}
+
+	id := map[string]int64{"id": 9007199254740993}
+
+	runCases(t, nil, []testCase{
+		{
+			name:    "strict",
+			test:    numbers(true, id),
+			handler: raw(`{"id":9007199254740993}`),
+		},
+		{
+			name:    "int64 precision lost",
+			test:    numbers(false, id),
+			handler: raw(`{"id":9007199254740992}`),
+		},
+		{
+			name:    "int64 precision",
+			test:    numbers(true, id),
+			handler: raw(`{"id":9007199254740992}`),
+			failure: "9007199254740992",
+		},
+		{
+			name:    "float as int",
+			test:    numbers(false, map[string]int{"n": 1}),
+			handler: raw(`{"n":1.0}`),
+		},
+		{
+			name:    "int vs float",
+			test:    numbers(true, map[string]int{"n": 1}),
+			handler: raw(`{"n":1.0}`),
+			failure: "1.0",
+		},
+	})
+}
+
+func TestUnorderedArrays(t *testing.T) {
+	handler := func(body string) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}))
+	}
+
+	shuffled := `{"items":[{"id":2,"tags":["b","a"]},{"id":1,"tags":["x"]}],"order":[1,2]}`
+
+	items := Test{
+		Method:           http.MethodGet,
+		Path:             "/items",
+		ExpectedStatus:   http.StatusOK,
+		ExpectedResponse: `{"items":[{"id":1,"tags":["x"]},{"id":2,"tags":["a","b"]}],"order":[1,2]}`,
+	}
+
+	unordered := items
+	unordered.UnorderedArrays = true
+
+	paths := items
+	paths.UnorderedPaths = []string{"items", "items.tags"}
+
+	items.UnorderedPaths = []string{"items"}
+
+	runCases(t, handler(shuffled), []testCase{
+		{
+			name: "unordered paths",
+			test: paths,
+		},
+		{
+			name: "unordered arrays",
+			test: unordered,
+		},
+		{
+			name:    "ordered",
+			test:    Test{Method: http.MethodGet, Path: "/items", ExpectedStatus: http.StatusOK, ExpectedResponse: items.ExpectedResponse},
+			failure: "$.items[0].id: expected 1, got 2",
+		},
+		{
+			name:    "path not unordered",
+			test:    items,
+			failure: "$.items[1].tags[0]",
+		},
+		{
+			name:    "element differs",
+			test:    unordered,
+			handler: handler(strings.Replace(shuffled, `"x"`, `"y"`, 1)),
+			failure: `"y"`,
+		},
+	})
+}
+
+func TestJSONDiffMessage(t *testing.T) {
+	handler := jsonHandler(http.StatusOK, &user{ID: "u1", Name: "Ann", Address: address{City: "Lyon"}})
+
+	failures := run(&Test{
+		Method:           http.MethodGet,
+		Path:             "/users/u1",
+		ExpectedStatus:   http.StatusOK,
+		ExpectedResponse: &user{ID: "u1", Name: "Ann", Address: address{City: "Paris"}},
+	}, &Mock{}, handler)
+
+	requireFailure(t, failures, "json diff:")
+	requireFailure(t, failures, `$.Address.City: expected "Paris", got "Lyon"`)
+
+	for _, f := range failures {
+		if strings.Contains(f, "$.ID") || strings.Contains(f, "$.Name") {
+			t.Errorf("diff reports unchanged fields: %s", f)
+		}
+	}
+}
+
+func TestBinaryResponse(t *testing.T) {
+	encode := func(c color.Color) []byte {
+		img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		img.Set(0, 0, c)
+
+		buf := &bytes.Buffer{}
+		if err := png.Encode(buf, img); err != nil {
+			t.Fatalf("failed to encode png: %s", err.Error())
+		}
+		return buf.Bytes()
+	}
+
+	red := encode(color.RGBA{R: 255, A: 255})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(red)
+	})
+
+	avatar := func(expected []byte) Test {
+		return Test{
+			Method:              http.MethodGet,
+			Path:                "/avatar.png",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "image/png",
+			ExpectedResponse:    expected,
+		}
+	}
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "match",
+			test: avatar(red),
+		},
+		{
+			name:    "mismatch",
+			test:    avatar(encode(color.RGBA{B: 255, A: 255})),
+			failure: "binary response mismatch: expected ",
+			check: func(tt *testing.T, test *Test, store *userStore) {
+				requireFailure(tt, run(test, &Mock{}, handler), "first difference at offset ")
+			},
+		},
+	})
+}
+
+func TestIgnoreArrayFields(t *testing.T) {
+	handler := jsonHandler(http.StatusOK, []map[string]string{{"id": "9f1", "name": "Ann"}, {"id": "c77", "name": "Bob"}})
+
+	users := func(names ...string) Test {
+		expected := make([]map[string]string, 0)
+		for i, n := range names {
+			expected = append(expected, map[string]string{"id": strconv.Itoa(i), "name": n})
+		}
+
+		return Test{
+			Method:            http.MethodGet,
+			Path:              "/users",
+			ExpectedStatus:    http.StatusOK,
+			ExpectedResponse:  expected,
+			IgnoreArrayFields: []string{"id"},
+		}
+	}
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "match",
+			test: users("Ann", "Bob"),
+		},
+		{
+			name:    "mismatch",
+			test:    users("Ann", "Eve"),
+			failure: `$[1].name: expected "Eve", got "Bob"`,
+		},
+	})
+}
+
+func TestAssertChanged(t *testing.T) {
+	handler := func(hash bool) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var m map[string]string
+			json.NewDecoder(r.Body).Decode(&m)
+
+			if hash {
+				m["password"] = "sha256:" + m["password"]
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(m)
+		}))
+	}
+
+	test := Test{
+		Method:          http.MethodPost,
+		Path:            "/users",
+		Request:         map[string]string{"name": "Ann", "password": "secret"},
+		ExpectedStatus:  http.StatusOK,
+		AssertChanged:   []string{"password"},
+		AssertUnchanged: []string{"name"},
+	}
+
+	runCases(t, handler(true), []testCase{
+		{
+			name: "hashed",
+			test: test,
+		},
+		{
+			name:    "echoed",
+			test:    test,
+			handler: handler(false),
+			failure: `field password is unchanged in response: "secret"`,
+		},
+	})
+}
+
+func TestFloatTolerance(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"price":19.990000001,"coords":[48.8566000009,2.3522],"count":3}`))
+	})
+
+	place := func(tolerance float64) Test {
+		return Test{
+			Method:           http.MethodGet,
+			Path:             "/places/paris",
+			ExpectedStatus:   http.StatusOK,
+			ExpectedResponse: map[string]interface{}{"price": 19.99, "coords": []float64{48.8566, 2.3522}, "count": 3},
+			FloatTolerance:   tolerance,
+		}
+	}
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "within tolerance",
+			test: place(1e-8),
+		},
+		{
+			name:    "exact",
+			test:    place(0),
+			failure: "$.price: expected 19.99, got 19.990000001",
+		},
+		{
+			name:    "out of tolerance",
+			test:    place(1e-10),
+			failure: "$.coords[0]: expected 48.8566, got 48.8566000009",
+		},
+	})
+}
+
+func TestStringNumberPaths(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"7","amount":"100","lines":[{"amount":"2.50"},{"amount":"97.50"}]}`))
+	})
+
+	invoice := func(paths ...string) Test {
+		return Test{
+			Method:         http.MethodGet,
+			Path:           "/invoices/7",
+			ExpectedStatus: http.StatusOK,
+			ExpectedResponse: map[string]interface{}{
+				"id":     "7",
+				"amount": 100,
+				"lines":  []map[string]interface{}{{"amount": 2.5}, {"amount": 97.5}},
+			},
+			StringNumberPaths: paths,
+		}
+	}
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "configured paths",
+			test: invoice("amount", "lines.amount"),
+		},
+		{
+			name:    "unconfigured path",
+			test:    invoice("amount"),
+			failure: `$.lines[0].amount: expected 2.5, got "2.50"`,
+		},
+		{
+			name:    "disabled",
+			test:    invoice(),
+			failure: `$.amount: expected 100, got "100"`,
+		},
+	})
+}
+
+func TestStrictKeyOrder(t *testing.T) {
+	handler := func(body string) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}))
+	}
+
+	ordered := `{"ID":"1","Name":"ada","Address":{"City":"London"}}`
+	reordered := `{"Name":"ada","ID":"1","Address":{"City":"London"}}`
+
+	keys := func(strict bool) Test {
+		return Test{
+			Method:           http.MethodGet,
+			Path:             "/users/1",
+			ExpectedStatus:   http.StatusOK,
+			ExpectedResponse: user{ID: "1", Name: "ada", Address: address{City: "London"}},
+			StrictKeyOrder:   strict,
+		}
+	}
+
+	runCases(t, nil, []testCase{
+		{
+			name:    "ordered",
+			test:    keys(true),
+			handler: handler(ordered),
+		},
+		{
+			name:    "not strict",
+			test:    keys(false),
+			handler: handler(reordered),
+		},
+		{
+			name:    "reordered",
+			test:    keys(true),
+			handler: handler(reordered),
+			failure: "unexpected json key order",
+		},
+	})
+}
+
+func TestExpectedContainsValue(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"org":{"teams":[{"members":[{"ID":"1","Name":"ada","Address":{"City":"London"}}]}]},"count":1}`))
+	})
+
+	contains := func(values ...interface{}) Test {
+		return Test{
+			Method:                http.MethodGet,
+			Path:                  "/orgs/1",
+			ExpectedStatus:        http.StatusOK,
+			ExpectedContainsValue: values,
+		}
+	}
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "contained",
+			test: contains("ada", 1, address{City: "London"}),
+		},
+		{
+			name:    "absent",
+			test:    contains("ada", "grace"),
+			failure: `response does not contain value "grace"`,
+		},
+		{
+			name:    "partial object",
+			test:    contains(map[string]string{"ID": "1"}),
+			failure: `response does not contain value {"ID":"1"}`,
+		},
+	})
+}
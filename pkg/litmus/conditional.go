@@ -0,0 +1,149 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// IfNoneMatch returns a setup func that sets the If-None-Match header to the etag
+func IfNoneMatch(etag string) func(r *http.Request) {
+	return func(r *http.Request) {
+		r.Header.Set("If-None-Match", etag)
+	}
+}
+
+// IfModifiedSince returns a setup func that sets the If-Modified-Since header to the time
+func IfModifiedSince(t time.Time) func(r *http.Request) {
+	return func(r *http.Request) {
+		r.Header.Set("If-Modified-Since", t.UTC().Format(http.TimeFormat))
+	}
+}
+
+// ConditionalRoundTrip returns a test that GETs the path, then replays the request against the same server with
+// If-None-Match set to the response ETag and asserts a 304 Not Modified response that does not call the backend;
+// the operations of the initial request must be set by the caller and, as with DoAgainst, the fields that observe the
+// request in the server are invalid
+func ConditionalRoundTrip(path string) *Test {
+	return &Test{
+		Method:         http.MethodGet,
+		Path:           path,
+		ExpectedStatus: http.StatusOK,
+		roundTrip:      true,
+	}
+}
+
+// DoConditional executes the test, then repeats the request conditionally with the ETag and Last-Modified
+// validators of the response and asserts a 304 Not Modified response with no body
+func (t *Test) DoConditional(backend Backend, handler http.Handler, tt *testing.T) {
+	tt.Helper()
+
+	validators := t.doInitial(backend, handler, tt)
+
+	etag := validators.Get("ETag")
+	modified := validators.Get("Last-Modified")
+
+	if etag == "" && modified == "" {
+		tt.Fatalf("response has no ETag or Last-Modified validator")
+	}
+
+	cond := t.conditional(etag, modified)
+
+	tt.Run("conditional", func(tt *testing.T) {
+		cond.do(backend, handler, tt)
+	})
+}
+
+// doRoundTrip executes the ConditionalRoundTrip test against a single server
+func (t *Test) doRoundTrip(backend Backend, handler http.Handler, tt *testing.T) {
+	tt.Helper()
+
+	ts := httptest.NewTLSServer(handler)
+	defer ts.Close()
+
+	t.server = ts
+	defer func() {
+		t.server = nil
+	}()
+
+	etag := t.doInitial(backend, nil, tt).Get("ETag")
+	if etag == "" {
+		tt.Fatalf("response has no ETag")
+	}
+
+	cond := t.conditional(etag, "")
+	cond.server = ts
+
+	calls := len(t.backendCalls(backend))
+
+	tt.Run("conditional", func(tt *testing.T) {
+		cond.do(backend, nil, tt)
+	})
+
+	if n := len(t.backendCalls(backend)) - calls; n > 0 {
+		tt.Errorf("conditional request called the backend %d times", n)
+	}
+}
+
+// doInitial executes the test and returns the response headers
+func (t *Test) doInitial(backend Backend, handler http.Handler, tt *testing.T) http.Header {
+	tt.Helper()
+
+	var validators http.Header
+
+	validate := t.ValidateResponse
+	t.ValidateResponse = func(t *Test, resp *http.Response, body []byte) error {
+		validators = resp.Header.Clone()
+		if validate != nil {
+			return validate(t, resp, body)
+		}
+		return nil
+	}
+	defer func() {
+		t.ValidateResponse = validate
+	}()
+
+	tt.Run("initial", func(tt *testing.T) {
+		t.do(backend, handler, tt)
+	})
+
+	return validators
+}
+
+// conditional returns the conditional replay of the test expecting a 304 Not Modified response with no body
+func (t *Test) conditional(etag, modified string) *Test {
+	setup := t.Setup
+
+	return &Test{
+		Operations: t.Operations,
+		Method:     t.Method,
+		BasePath:   t.BasePath,
+		Path:       t.Path,
+		Query:      t.Query,
+		Host:       t.Host,
+		Env:        t.Env,
+		Setup: func(r *http.Request) {
+			if setup != nil {
+				setup(r)
+			}
+			if etag != "" {
+				r.Header.Set("If-None-Match", etag)
+			}
+			if modified != "" {
+				r.Header.Set("If-Modified-Since", modified)
+			}
+		},
+		ExpectedStatus:  http.StatusNotModified,
+		ExpectEmptyBody: true,
+		replay:          true,
+	}
+}
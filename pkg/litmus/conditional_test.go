@@ -0,0 +1,139 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestConditional(t *testing.T) {
+	handler := func(conditional bool) func(*userStore) http.Handler {
+		return func(store *userStore) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				etag := `"v1"`
+
+				w.Header().Set("ETag", etag)
+				w.Header().Set("Cache-Control", "max-age=60")
+
+				if conditional && r.Header.Get("If-None-Match") == etag {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+
+				u, _ := store.Get("u1")
+
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(u)
+			})
+		}
+	}
+
+	store := &userStore{}
+
+	(&Test{
+		Operations:       []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}}},
+		Method:           http.MethodGet,
+		Path:             "/users/u1",
+		ExpectedStatus:   http.StatusOK,
+		ExpectedHeaders:  map[string]string{"Cache-Control": "^max-age=60$", "ETag": `^"v1"$`},
+		ExpectedResponse: OperationReturn(0),
+	}).DoConditional(&store.Mock, handler(true)(store), t)
+
+	roundTrip := *ConditionalRoundTrip("/users/u1")
+	roundTrip.Operations = []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}}}
+
+	runCases(t, handler(true), []testCase{
+		{
+			name: "round trip",
+			test: roundTrip,
+		},
+		{
+			name: "if none match",
+			test: Test{
+				Method:          http.MethodGet,
+				Path:            "/users/u1",
+				Setup:           IfNoneMatch(`"v1"`),
+				ExpectedStatus:  http.StatusNotModified,
+				ExpectEmptyBody: true,
+			},
+		},
+		{
+			name: "if none match ignored",
+			test: Test{
+				Operations:      []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}}},
+				Method:          http.MethodGet,
+				Path:            "/users/u1",
+				Setup:           IfNoneMatch(`"v1"`),
+				ExpectedStatus:  http.StatusNotModified,
+				ExpectEmptyBody: true,
+			},
+			handler: handler(false),
+			failure: "actual  : 200",
+		},
+	})
+}
+
+func TestConditionalRoundTrip(t *testing.T) {
+	handler := func(store *userStore, mode string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if mode == "leaky" {
+				store.Get("u1")
+			}
+
+			if mode != "untagged" {
+				w.Header().Set("ETag", `"v1"`)
+			}
+
+			if mode != "ignored" && r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			u, _ := store.Get("u1")
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(u)
+		})
+	}
+
+	cases := []struct {
+		mode    string
+		failure string
+	}{
+		{mode: "cached"},
+		{mode: "ignored", failure: "actual  : 200"},
+		{mode: "untagged", failure: "response has no ETag"},
+		{mode: "leaky", failure: "conditional request called the backend 1 times"},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.mode, func(t *testing.T) {
+			// the failing round trips run in a child process
+			if c.failure != "" && !inSubprocess(t) {
+				out := subprocess(t)
+				if !strings.Contains(out, "--- FAIL: TestConditionalRoundTrip/"+c.mode) || !strings.Contains(out, c.failure) {
+					t.Errorf("%s round trip not reported with %q:\n%s", c.mode, c.failure, out)
+				}
+				return
+			}
+
+			test := ConditionalRoundTrip("/users/u1")
+			test.Operations = []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}}}
+			test.ExpectedResponse = OperationReturn(0)
+
+			store := &userStore{}
+			test.Do(&store.Mock, handler(store, c.mode), t)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Preflight returns a CORS preflight test expecting the origin, method and headers to be allowed;
+// the expected status defaults to 204 and the path must be set by the caller
+func Preflight(origin, method string, headers ...string) *Test {
+	return &Test{
+		Method:         http.MethodOptions,
+		Setup:          preflightSetup(origin, method, headers),
+		ExpectedStatus: http.StatusNoContent,
+		ValidateResponse: func(t *Test, resp *http.Response, body []byte) error {
+			if o := resp.Header.Get("Access-Control-Allow-Origin"); o != "*" && o != origin {
+				return fmt.Errorf("origin %s not allowed: Access-Control-Allow-Origin %q", origin, o)
+			}
+
+			if !headerListContains(resp.Header, "Access-Control-Allow-Methods", method) {
+				return fmt.Errorf("method %s not allowed: Access-Control-Allow-Methods %q", method, resp.Header.Get("Access-Control-Allow-Methods"))
+			}
+
+			for _, h := range headers {
+				if !headerListContains(resp.Header, "Access-Control-Allow-Headers", h) {
+					return fmt.Errorf("header %s not allowed: Access-Control-Allow-Headers %q", h, resp.Header.Get("Access-Control-Allow-Headers"))
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+// PreflightDenied returns a CORS preflight test expecting the origin to be rejected;
+// the expected status defaults to 204 and the path must be set by the caller
+func PreflightDenied(origin, method string, headers ...string) *Test {
+	return &Test{
+		Method:         http.MethodOptions,
+		Setup:          preflightSetup(origin, method, headers),
+		ExpectedStatus: http.StatusNoContent,
+		ValidateResponse: func(t *Test, resp *http.Response, body []byte) error {
+			if o := resp.Header.Get("Access-Control-Allow-Origin"); o == "*" || o == origin {
+				return fmt.Errorf("origin %s unexpectedly allowed: Access-Control-Allow-Origin %q", origin, o)
+			}
+			return nil
+		},
+	}
+}
+
+func preflightSetup(origin, method string, headers []string) func(r *http.Request) {
+	return func(r *http.Request) {
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", method)
+
+		if len(headers) > 0 {
+			r.Header.Set("Access-Control-Request-Headers", strings.Join(headers, ", "))
+		}
+	}
+}
+
+// headerListContains checks if the comma separated header values contain v, ignoring case
+func headerListContains(h http.Header, key, v string) bool {
+	for _, line := range h.Values(key) {
+		for _, item := range strings.Split(line, ",") {
+			item = strings.TrimSpace(item)
+			if item == "*" || strings.EqualFold(item, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
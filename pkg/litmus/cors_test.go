@@ -0,0 +1,57 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPreflight(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions && r.Header.Get("Origin") == "https://app.example.com" {
+			w.Header().Set("Access-Control-Allow-Origin", "https://app.example.com")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, PUT")
+			w.Header().Set("Access-Control-Allow-Headers", "content-type, x-token")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// at sets the path of the preflight test
+	at := func(p *Test) Test {
+		p.Path = "/users/u1"
+		return *p
+	}
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "allowed",
+			test: at(Preflight("https://app.example.com", http.MethodPut, "X-Token", "Content-Type")),
+		},
+		{
+			name: "denied",
+			test: at(PreflightDenied("https://evil.example.com", http.MethodPut)),
+		},
+		{
+			name:    "origin not allowed",
+			test:    at(Preflight("https://evil.example.com", http.MethodPut)),
+			failure: "origin https://evil.example.com not allowed",
+		},
+		{
+			name:    "method not allowed",
+			test:    at(Preflight("https://app.example.com", http.MethodDelete)),
+			failure: "method DELETE not allowed",
+		},
+		{
+			name:    "origin allowed",
+			test:    at(PreflightDenied("https://app.example.com", http.MethodPut)),
+			failure: "origin https://app.example.com unexpectedly allowed",
+		},
+	})
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+
+	"github.com/stretchr/testify/require"
+)
+
+// isCSVContentType returns true for csv media types
+func isCSVContentType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mt == "text/csv"
+}
+
+// csvEq compares the expected csv, raw or as [][]string records, with the actual csv by record
+func (t *Test) csvEq(tt testingT, expected interface{}, actual []byte) {
+	tt.Helper()
+
+	var want [][]string
+
+	switch m := expected.(type) {
+	case [][]string:
+		want = m
+	case []byte:
+		want = parseCSV(tt, "expected", string(m))
+	case string:
+		want = parseCSV(tt, "expected", m)
+	default:
+		tt.Fatalf("unsupported csv response type %T", expected)
+	}
+
+	got := parseCSV(tt, "actual", string(actual))
+
+	if t.IgnoreCSVHeader {
+		want = dropHeader(want)
+		got = dropHeader(got)
+	}
+
+	if t.UnorderedRows {
+		want = sortRecords(want)
+		got = sortRecords(got)
+	}
+
+	require.Equal(tt, want, got, "unexpected csv records")
+}
+
+func parseCSV(tt testingT, name, doc string) [][]string {
+	tt.Helper()
+
+	r := csv.NewReader(strings.NewReader(doc))
+	r.FieldsPerRecord = -1
+
+	records, err := r.ReadAll()
+	if err != nil {
+		tt.Fatalf("failed to parse %s csv: %s", name, err.Error())
+	}
+
+	if records == nil {
+		records = [][]string{}
+	}
+
+	return records
+}
+
+func dropHeader(records [][]string) [][]string {
+	if len(records) == 0 {
+		return records
+	}
+	return records[1:]
+}
+
+// sortRecords returns a sorted copy of the records
+func sortRecords(records [][]string) [][]string {
+	sorted := append([][]string{}, records...)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return fmt.Sprintf("%q", sorted[i]) < fmt.Sprintf("%q", sorted[j])
+	})
+
+	return sorted
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCSVResponse(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Write([]byte("name,total\n\"bob\",2\nalice,\"1\"\n"))
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "unordered",
+			test: Test{
+				Method:              http.MethodGet,
+				Path:                "/report",
+				ExpectedStatus:      http.StatusOK,
+				ExpectedContentType: "text/csv; charset=utf-8",
+				ExpectedResponse:    "name,total\nalice,1\nbob,2\n",
+				UnorderedRows:       true,
+			},
+		},
+		{
+			name: "header ignored",
+			test: Test{
+				Method:              http.MethodGet,
+				Path:                "/report",
+				ExpectedStatus:      http.StatusOK,
+				ExpectedContentType: "text/csv; charset=utf-8",
+				ExpectedResponse:    [][]string{{"who", "count"}, {"alice", "1"}, {"bob", "2"}},
+				UnorderedRows:       true,
+				IgnoreCSVHeader:     true,
+			},
+		},
+		{
+			name: "ordered",
+			test: Test{
+				Method:              http.MethodGet,
+				Path:                "/report",
+				ExpectedStatus:      http.StatusOK,
+				ExpectedContentType: "text/csv; charset=utf-8",
+				ExpectedResponse:    "name,total\nalice,1\nbob,2\n",
+			},
+			failure: "unexpected csv records",
+		},
+		{
+			name: "header",
+			test: Test{
+				Method:              http.MethodGet,
+				Path:                "/report",
+				ExpectedStatus:      http.StatusOK,
+				ExpectedContentType: "text/csv; charset=utf-8",
+				ExpectedResponse:    "who,count\nalice,1\nbob,2\n",
+				UnorderedRows:       true,
+			},
+			failure: "unexpected csv records",
+		},
+	})
+}
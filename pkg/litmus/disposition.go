@@ -0,0 +1,49 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+type (
+	// Disposition is an expected Content-Disposition header
+	Disposition struct {
+		// Type is the disposition type, i.e. attachment or inline
+		Type string
+
+		// Filename is the decoded filename, RFC 5987 filename* takes precedence over filename
+		Filename string
+	}
+)
+
+// assertDisposition parses the Content-Disposition header and compares it with ExpectedDisposition
+func (t *Test) assertDisposition(tt testingT, h http.Header) {
+	tt.Helper()
+
+	v := h.Get("Content-Disposition")
+	if v == "" {
+		tt.Fatalf("response header Content-Disposition missing")
+	}
+
+	typ, params, err := mime.ParseMediaType(v)
+	if err != nil {
+		tt.Fatalf("failed to parse Content-Disposition: %s", err.Error())
+	}
+
+	if !strings.EqualFold(typ, t.ExpectedDisposition.Type) {
+		tt.Errorf("unexpected disposition type %q, expected %q", typ, t.ExpectedDisposition.Type)
+	}
+
+	if params["filename"] != t.ExpectedDisposition.Filename {
+		tt.Errorf("unexpected disposition filename %q, expected %q", params["filename"], t.ExpectedDisposition.Filename)
+	}
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestExpectedDisposition(t *testing.T) {
+	handler := func(disposition string) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if disposition != "" {
+				w.Header().Set("Content-Disposition", disposition)
+			}
+			w.Header().Set("Content-Type", "text/csv")
+			w.Write([]byte("id,name\n1,ada\n"))
+		}))
+	}
+
+	test := Test{
+		Method:         http.MethodGet,
+		Path:           "/reports/1",
+		ExpectedStatus: http.StatusOK,
+		ExpectedDisposition: &Disposition{
+			Type:     "attachment",
+			Filename: "résumé 2020.csv",
+		},
+	}
+
+	runCases(t, nil, []testCase{
+		{
+			name:    "encoded with fallback",
+			test:    test,
+			handler: handler(`attachment; filename="resume 2020.csv"; filename*=UTF-8''r%C3%A9sum%C3%A9%202020.csv`),
+		},
+		{
+			name:    "encoded",
+			test:    test,
+			handler: handler(`Attachment; filename*=utf-8''r%C3%A9sum%C3%A9%202020.csv`),
+		},
+		{
+			name:    "missing",
+			test:    test,
+			handler: handler(""),
+			failure: "response header Content-Disposition missing",
+		},
+		{
+			name:    "inline",
+			test:    test,
+			handler: handler(`inline; filename*=UTF-8''r%C3%A9sum%C3%A9%202020.csv`),
+			failure: `unexpected disposition type "inline", expected "attachment"`,
+		},
+		{
+			name:    "ascii fallback",
+			test:    test,
+			handler: handler(`attachment; filename="resume 2020.csv"`),
+			failure: `unexpected disposition filename "resume 2020.csv", expected "résumé 2020.csv"`,
+		},
+		{
+			name:    "malformed",
+			test:    test,
+			handler: handler(`attachment; filename`),
+			failure: "failed to parse Content-Disposition",
+		},
+	})
+}
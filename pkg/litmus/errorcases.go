@@ -0,0 +1,72 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ErrorCases returns a test per error to status mapping, ordered by status and error, with the operation returning
+// the error as its last return value; the optional base test is copied for each case to provide the request and
+// the operation args and leading returns, otherwise they must be set by the caller
+func ErrorCases(op string, cases map[error]int, base ...*Test) []*Test {
+	errs := make([]error, 0, len(cases))
+	for err := range cases {
+		errs = append(errs, err)
+	}
+
+	sort.Slice(errs, func(i, j int) bool {
+		if cases[errs[i]] != cases[errs[j]] {
+			return cases[errs[i]] < cases[errs[j]]
+		}
+		return errs[i].Error() < errs[j].Error()
+	})
+
+	tests := make([]*Test, 0, len(errs))
+
+	for _, err := range errs {
+		t := &Test{}
+		if len(base) > 0 && base[0] != nil {
+			*t = *base[0]
+		}
+
+		t.Name = fmt.Sprintf("%s %s %d", op, err.Error(), cases[err])
+		t.ExpectedStatus = cases[err]
+		t.ExpectedResponse = nil
+		t.Operations = append([]Operation{}, t.Operations...)
+
+		found := false
+		for i, o := range t.Operations {
+			if o.Name != op {
+				continue
+			}
+
+			returns := append(Returns{}, o.Returns...)
+			if len(returns) == 0 {
+				returns = Returns{err}
+			} else {
+				returns[len(returns)-1] = err
+			}
+
+			o.Returns = returns
+			o.ReturnStack = nil
+			t.Operations[i] = o
+			found = true
+		}
+
+		if !found {
+			t.Operations = append(t.Operations, Operation{Name: op, Returns: Returns{err}})
+		}
+
+		tests = append(tests, t)
+	}
+
+	return tests
+}
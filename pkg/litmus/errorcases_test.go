@@ -0,0 +1,72 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+var (
+	errNotFound  = errors.New("not found")
+	errForbidden = errors.New("forbidden")
+	errStorage   = errors.New("storage failure")
+)
+
+func TestErrorCases(t *testing.T) {
+	handler := func(notFound int) func(*userStore) http.Handler {
+		return func(store *userStore) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch _, err := store.Get("u1"); err {
+				case nil:
+					w.WriteHeader(http.StatusOK)
+				case errNotFound:
+					w.WriteHeader(notFound)
+				case errForbidden:
+					w.WriteHeader(http.StatusForbidden)
+				default:
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			})
+		}
+	}
+
+	base := &Test{
+		Operations: []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}}},
+		Method:     http.MethodGet,
+		Path:       "/users/u1",
+	}
+
+	tests := ErrorCases("Get", map[error]int{
+		errNotFound:  http.StatusNotFound,
+		errForbidden: http.StatusForbidden,
+		errStorage:   http.StatusInternalServerError,
+	}, base)
+
+	if len(tests) != 3 {
+		t.Fatalf("%d tests, expected 3", len(tests))
+	}
+
+	if base.Operations[0].Returns[1] != nil {
+		t.Errorf("base test operation returns modified")
+	}
+
+	var cases []testCase
+	for _, test := range tests {
+		cases = append(cases, testCase{name: test.Name, test: *test})
+	}
+
+	runCases(t, handler(http.StatusNotFound), append(cases, testCase{
+		name:    "wrong status",
+		test:    *tests[1],
+		handler: handler(http.StatusGone),
+		failure: "actual  : 410",
+	}))
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+type (
+	// timing records when operations complete and when the response is first flushed
+	timing struct {
+		sync.Mutex
+
+		calls   map[string]time.Time
+		flushed time.Time
+	}
+
+	// flushWriter records the first flush of the wrapped response writer
+	flushWriter struct {
+		http.ResponseWriter
+
+		timing *timing
+	}
+)
+
+func newTiming() *timing {
+	return &timing{
+		calls: make(map[string]time.Time),
+	}
+}
+
+// called records the completion of the operation
+func (c *timing) called(name string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.calls[name] = time.Now()
+}
+
+// flush records the first flush of the response
+func (c *timing) flush() {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.flushed.IsZero() {
+		c.flushed = time.Now()
+	}
+}
+
+// Flush implements the http.Flusher interface
+func (w *flushWriter) Flush() {
+	w.timing.flush()
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// assertFlushOrder checks the FlushAfter operations completed before the response was flushed
+func (t *Test) assertFlushOrder(tt testingT) {
+	tt.Helper()
+
+	t.timing.Lock()
+	defer t.timing.Unlock()
+
+	for _, name := range t.FlushAfter {
+		at, ok := t.timing.calls[name]
+		if !ok {
+			tt.Errorf("operation %s was not called before the response was flushed", name)
+			continue
+		}
+
+		if at.After(t.timing.flushed) {
+			tt.Errorf("operation %s completed after the response was flushed", name)
+		}
+	}
+}
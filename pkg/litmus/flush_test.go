@@ -0,0 +1,93 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+func TestFlushAfter(t *testing.T) {
+	handler := func(saveFirst bool) func(*userStore) http.Handler {
+		return func(store *userStore) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if saveFirst {
+					store.Save(&user{ID: "u1"})
+				}
+
+				w.Write([]byte("saving\n"))
+				w.(http.Flusher).Flush()
+
+				if !saveFirst {
+					store.Save(&user{ID: "u1"})
+				}
+
+				w.Write([]byte("done\n"))
+			})
+		}
+	}
+
+	test := Test{
+		Operations:       []Operation{{Name: "Save", Args: Args{&user{}}, Returns: Returns{nil}}},
+		Method:           http.MethodPost,
+		Path:             "/users/u1/save",
+		ExpectedStatus:   http.StatusOK,
+		ExpectedResponse: regexp.MustCompile("^saving\ndone\n$"),
+		FlushAfter:       []string{"Save"},
+	}
+
+	runCases(t, handler(true), []testCase{
+		{
+			name: "saved first",
+			test: test,
+		},
+		{
+			name:    "flushed first",
+			test:    test,
+			handler: handler(false),
+			failure: "operation Save completed after the response was flushed",
+		},
+	})
+}
+
+func TestExpectChunked(t *testing.T) {
+	handler := func(flush bool) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for i := 0; i < 3; i++ {
+				fmt.Fprintf(w, "data: %d\n\n", i)
+				if flush {
+					w.(http.Flusher).Flush()
+				}
+			}
+		}))
+	}
+
+	test := Test{
+		Method:         http.MethodGet,
+		Path:           "/events",
+		ExpectedStatus: http.StatusOK,
+		ExpectChunked:  true,
+	}
+
+	runCases(t, handler(true), []testCase{
+		{
+			name: "flushed",
+			test: test,
+		},
+		{
+			name:    "buffered",
+			test:    test,
+			handler: handler(false),
+			failure: "unexpected response transfer encoding",
+		},
+	})
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"mime"
+	"net/url"
+
+	"github.com/stretchr/testify/require"
+)
+
+// isFormContentType returns true for url-encoded form media types
+func isFormContentType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mt == "application/x-www-form-urlencoded"
+}
+
+// formEq compares the expected form, encoded or as url.Values or map[string]string, with the actual form by value
+func formEq(tt testingT, expected interface{}, actual []byte) {
+	tt.Helper()
+
+	var want url.Values
+
+	switch m := expected.(type) {
+	case url.Values:
+		want = m
+	case map[string]string:
+		want = make(url.Values)
+		for k, v := range m {
+			want.Set(k, v)
+		}
+	case []byte:
+		want = parseForm(tt, "expected", string(m))
+	case string:
+		want = parseForm(tt, "expected", m)
+	default:
+		tt.Fatalf("unsupported form response type %T", expected)
+	}
+
+	got := parseForm(tt, "actual", string(actual))
+
+	require.Equal(tt, want, got, "unexpected form values")
+}
+
+func parseForm(tt testingT, name, doc string) url.Values {
+	tt.Helper()
+
+	v, err := url.ParseQuery(doc)
+	if err != nil {
+		tt.Fatalf("failed to parse %s form: %s", name, err.Error())
+	}
+
+	return v
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestFormResponse(t *testing.T) {
+	handler := func(body string) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+			w.Write([]byte(body))
+		}))
+	}
+
+	token := "access_token=2YotnFZFEjr1zCsicMWpAA&token_type=bearer&expires_in=3600&scope=read+write"
+
+	form := func(expected interface{}) Test {
+		return Test{
+			Method:              http.MethodPost,
+			Path:                "/oauth/token",
+			Request:             "grant_type=client_credentials",
+			RequestContentType:  "application/x-www-form-urlencoded",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/x-www-form-urlencoded",
+			ExpectedResponse:    expected,
+		}
+	}
+
+	runCases(t, handler(token), []testCase{
+		{
+			name: "string",
+			test: form("scope=read%20write&expires_in=3600&token_type=bearer&access_token=2YotnFZFEjr1zCsicMWpAA"),
+		},
+		{
+			name: "bytes",
+			test: form([]byte(token)),
+		},
+		{
+			name: "map",
+			test: form(map[string]string{
+				"access_token": "2YotnFZFEjr1zCsicMWpAA",
+				"token_type":   "bearer",
+				"expires_in":   "3600",
+				"scope":        "read write",
+			}),
+		},
+		{
+			name: "values",
+			test: form(url.Values{
+				"access_token": []string{"2YotnFZFEjr1zCsicMWpAA"},
+				"token_type":   []string{"bearer"},
+				"expires_in":   []string{"3600"},
+				"scope":        []string{"read write"},
+			}),
+		},
+		{
+			name:    "value",
+			test:    form("access_token=2YotnFZFEjr1zCsicMWpAA&token_type=mac&expires_in=3600&scope=read+write"),
+			failure: "unexpected form values",
+		},
+		{
+			name:    "missing",
+			test:    form(token),
+			handler: handler("access_token=2YotnFZFEjr1zCsicMWpAA"),
+			failure: "unexpected form values",
+		},
+		{
+			name:    "malformed",
+			test:    form(token),
+			handler: handler("access_token=%zz"),
+			failure: "failed to parse actual form",
+		},
+		{
+			name:    "unsupported",
+			test:    form(3600),
+			failure: "unsupported form response type int",
+		},
+	})
+}
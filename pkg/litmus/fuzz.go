@@ -0,0 +1,128 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Fuzz sends fuzzed request bodies seeded from Request to the handler, failing if it panics or responds with a status
+// not in FuzzStatuses, or a 5xx status if unset; the operations are optional for every input
+//
+//	func FuzzCreateUser(f *testing.F) {
+//		test := &litmus.Test{Method: http.MethodPost, Path: "/users", Request: user}
+//		test.Fuzz(f, &backend.Mock, handler)
+//	}
+func (t *Test) Fuzz(f *testing.F, backend Backend, handler http.Handler) {
+	seed, err := t.seedBody(backend)
+	if err != nil {
+		f.Fatalf("failed to build seed request: %s", err.Error())
+	}
+	f.Add(seed)
+
+	if b, ok := backend.(boundBackend); ok {
+		b.bind(t)
+	} else if len(t.DefaultReturns) > 0 {
+		f.Fatalf("DefaultReturns requires a backend embedding Mock, got %T", backend)
+	}
+
+	t.registerOperations(backend)
+	for _, o := range t.Operations {
+		o.call.Maybe()
+	}
+
+	f.Fuzz(func(tt *testing.T, body []byte) {
+		panics := make(chan interface{}, 1)
+
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if v := recover(); v != nil {
+					panics <- v
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}()
+
+			handler.ServeHTTP(w, r)
+		}))
+		defer ts.Close()
+
+		tc := *t
+		tc.Request = body
+
+		req := tc.newRequest(backend, ts.URL, tt)
+
+		if t.Setup != nil {
+			t.Setup(req)
+		}
+
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			tt.Fatalf("failed to execute request: %s", err.Error())
+		}
+		defer resp.Body.Close()
+
+		select {
+		case v := <-panics:
+			tt.Fatalf("handler panicked with body %q: %v", body, v)
+		default:
+		}
+
+		if !t.fuzzStatus(resp.StatusCode) {
+			tt.Fatalf("unexpected status %d with body %q", resp.StatusCode, body)
+		}
+	})
+}
+
+// seedBody returns the request body used to seed the fuzz corpus
+func (t *Test) seedBody(backend Backend) ([]byte, error) {
+	switch m := t.Request.(type) {
+	case nil:
+		return []byte{}, nil
+	case []byte:
+		return m, nil
+	case string:
+		return []byte(m), nil
+	case RequestHandler:
+		r, err := m(backend, t)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	case *OperationRef:
+		v, err := m.resolve(t.Operations[m.Index].Args[m.Arg])
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	default:
+		return json.Marshal(m)
+	}
+}
+
+// fuzzStatus returns true if the status is acceptable for a fuzzed request
+func (t *Test) fuzzStatus(status int) bool {
+	if len(t.FuzzStatuses) == 0 {
+		return status < http.StatusInternalServerError
+	}
+
+	for _, s := range t.FuzzStatuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
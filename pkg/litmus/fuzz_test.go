@@ -0,0 +1,81 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// createUser decodes the user and saves it, rejecting invalid bodies
+func createUser(store *userStore, validate bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var u user
+		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if validate && u.ID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := store.Save(&u); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	})
+}
+
+func FuzzCreateUser(f *testing.F) {
+	store := &userStore{}
+
+	(&Test{
+		Operations:   []Operation{{Name: "Save", Args: Args{&user{}}, Returns: Returns{nil}}},
+		Method:       http.MethodPost,
+		Path:         "/users",
+		Request:      &user{ID: "u1", Name: "Ann"},
+		FuzzStatuses: []int{http.StatusCreated, http.StatusBadRequest},
+	}).Fuzz(f, store, createUser(store, true))
+}
+
+// FuzzUnexpectedStatus is run by TestFuzzStatus, its seed is answered with a status not in FuzzStatuses
+func FuzzUnexpectedStatus(f *testing.F) {
+	if os.Getenv(subprocessEnv) != "TestFuzzStatus" {
+		f.Skip("run by TestFuzzStatus")
+	}
+
+	store := &userStore{}
+
+	(&Test{
+		Operations:   []Operation{{Name: "Save", Args: Args{&user{}}, Returns: Returns{errors.New("duplicate user")}}},
+		Method:       http.MethodPost,
+		Path:         "/users",
+		Request:      &user{ID: "u1"},
+		FuzzStatuses: []int{http.StatusCreated, http.StatusBadRequest},
+	}).Fuzz(f, &store.Mock, createUser(store, false))
+}
+
+func TestFuzzStatus(t *testing.T) {
+	out := subprocessRun(t, "FuzzUnexpectedStatus")
+
+	if !strings.Contains(out, `unexpected status 500 with body "{\"ID\":\"u1\"`) {
+		t.Errorf("unexpected status not reported:\n%s", out)
+	}
+}
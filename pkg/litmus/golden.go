@@ -0,0 +1,97 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	// Update rewrites golden files with the actual responses, enabled by the LITMUS_UPDATE environment variable
+	// or set by the caller, i.e. from its own -update test flag in TestMain. The package does not register an
+	// -update flag itself, it would clash with the -update flag of any test binary importing litmus
+	Update = os.Getenv("LITMUS_UPDATE") != ""
+)
+
+// golden compares the response body to the golden file, rewriting it in update mode;
+// golden files ending in .gz and gzip compressed responses are compared decompressed
+func (t *Test) golden(tt testingT, contentType string, data []byte) {
+	tt.Helper()
+
+	data, err := gunzip(data)
+	if err != nil {
+		tt.Fatalf("failed to decompress response: %s", err.Error())
+	}
+
+	compressed := strings.HasSuffix(t.Golden, ".gz")
+
+	if Update {
+		if compressed {
+			buf := &bytes.Buffer{}
+			zw := gzip.NewWriter(buf)
+			if _, err := zw.Write(data); err != nil {
+				tt.Fatalf("failed to compress golden file: %s", err.Error())
+			}
+			if err := zw.Close(); err != nil {
+				tt.Fatalf("failed to compress golden file: %s", err.Error())
+			}
+			data = buf.Bytes()
+		}
+
+		if err := os.MkdirAll(filepath.Dir(t.Golden), 0755); err != nil {
+			tt.Fatalf("failed to create golden file directory: %s", err.Error())
+		}
+		if err := ioutil.WriteFile(t.Golden, data, 0644); err != nil {
+			tt.Fatalf("failed to update golden file: %s", err.Error())
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(t.Golden)
+	if err != nil {
+		tt.Fatalf("failed to read golden file: %s", err.Error())
+	}
+
+	if compressed {
+		if expected, err = gunzip(expected); err != nil {
+			tt.Fatalf("failed to decompress golden file: %s", err.Error())
+		}
+	}
+
+	switch {
+	case isJSONContentType(contentType):
+		t.jsonEq(tt, string(expected), string(data))
+	case isXMLContentType(contentType):
+		xmlEq(tt, string(expected), string(data))
+	default:
+		require.Equal(tt, string(expected), string(data))
+	}
+}
+
+// gunzip decompresses gzip data, other data is returned as is
+func gunzip(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return ioutil.ReadAll(zr)
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "litmus")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	ann := &user{ID: "u1", Name: "Ann", Address: address{City: "Paris"}}
+
+	cases := []struct {
+		name    string
+		golden  string
+		user    *user
+		update  bool
+		failure string
+	}{
+		{name: "match", golden: "testdata/user.golden.json", user: ann},
+		{name: "mismatch", golden: "testdata/user.golden.json", user: &user{ID: "u1", Name: "Bob"}, failure: "Bob"},
+		{name: "update", golden: filepath.Join(dir, "golden", "user.json"), user: ann, update: true},
+		{name: "updated", golden: filepath.Join(dir, "golden", "user.json"), user: ann},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			test := &Test{
+				Method:         http.MethodGet,
+				Path:           "/users/u1",
+				ExpectedStatus: http.StatusOK,
+				Golden:         c.golden,
+			}
+
+			if c.failure != "" {
+				requireFailure(t, run(test, &Mock{}, jsonHandler(http.StatusOK, c.user)), c.failure)
+				return
+			}
+
+			Update = c.update
+			defer func() { Update = false }()
+
+			test.Do(&Mock{}, jsonHandler(http.StatusOK, c.user), t)
+
+			if !c.update {
+				return
+			}
+
+			data, err := ioutil.ReadFile(c.golden)
+			if err != nil {
+				t.Fatalf("failed to read golden file: %s", err.Error())
+			}
+
+			if string(data) != `{"ID":"u1","Name":"Ann","Address":{"City":"Paris"}}`+"\n" {
+				t.Errorf("unexpected golden file %q", data)
+			}
+		})
+	}
+}
+
+func TestGoldenGzip(t *testing.T) {
+	handler := func(u *user) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+
+			if r.Header.Get("Accept-Encoding") != "gzip" {
+				json.NewEncoder(w).Encode(u)
+				return
+			}
+
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			json.NewEncoder(zw).Encode(u)
+			zw.Close()
+		}))
+	}
+
+	golden := func(encoding string) Test {
+		return Test{
+			Method:         http.MethodGet,
+			Path:           "/users/u1",
+			Setup:          func(r *http.Request) { r.Header.Set("Accept-Encoding", encoding) },
+			ExpectedStatus: http.StatusOK,
+			Golden:         "testdata/user.golden.json.gz",
+		}
+	}
+
+	runCases(t, handler(&user{ID: "u1", Name: "Ann", Address: address{City: "Paris"}}), []testCase{
+		{
+			name: "identity",
+			test: golden("identity"),
+		},
+		{
+			name: "gzip",
+			test: golden("gzip"),
+		},
+		{
+			name:    "mismatch",
+			test:    golden("gzip"),
+			handler: handler(&user{ID: "u1", Name: "Bob"}),
+			failure: `$.Name: expected "Ann", got "Bob"`,
+		},
+	})
+}
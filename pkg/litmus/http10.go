@@ -0,0 +1,179 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+type (
+	// http10Transport sends each request as HTTP/1.0 on a new connection, with tls for https urls
+	http10Transport struct {
+		config *tls.Config
+	}
+
+	// connBody closes the connection with the response body
+	connBody struct {
+		io.ReadCloser
+
+		conn io.Closer
+		done chan struct{}
+		once sync.Once
+	}
+)
+
+// RoundTrip implements the http.RoundTripper interface
+func (tr *http10Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// HTTP/1.0 has no chunked encoding, the body length must be known
+	if req.Body != nil && req.ContentLength <= 0 {
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+
+		req.ContentLength = int64(len(data))
+		req.Body = ioutil.NopCloser(bytes.NewReader(data))
+		req.TransferEncoding = nil
+	}
+
+	buf := &bytes.Buffer{}
+	if err := req.Write(buf); err != nil {
+		return nil, err
+	}
+
+	data := bytes.Replace(buf.Bytes(), []byte(" HTTP/1.1\r\n"), []byte(" HTTP/1.0\r\n"), 1)
+
+	ctx := req.Context()
+
+	conn, err := tr.dial(ctx, req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	// close the connection when the request is canceled or times out
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	body := &connBody{conn: conn, done: done}
+
+	if _, err := conn.Write(data); err != nil {
+		body.closeConn()
+		return nil, contextError(ctx, err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		body.closeConn()
+		return nil, contextError(ctx, err)
+	}
+
+	if tc, ok := conn.(*tls.Conn); ok {
+		state := tc.ConnectionState()
+		resp.TLS = &state
+	}
+
+	body.ReadCloser = resp.Body
+	resp.Body = body
+
+	return resp, nil
+}
+
+// dial connects to the url host honoring the context, with tls for https urls
+func (tr *http10Transport) dial(ctx context.Context, u *url.URL) (net.Conn, error) {
+	addr := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	d := &net.Dialer{}
+
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if u.Scheme != "https" {
+		return conn, nil
+	}
+
+	config := &tls.Config{}
+	if tr.config != nil {
+		config = tr.config.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = u.Hostname()
+	}
+
+	tc := tls.Client(conn, config)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- tc.Handshake()
+	}()
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	case <-ctx.Done():
+		conn.Close()
+		return nil, ctx.Err()
+	}
+
+	return tc, nil
+}
+
+// contextError returns the context error if the request was canceled or timed out, err otherwise
+func contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// Close closes the body and the connection
+func (b *connBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.closeConn()
+	return err
+}
+
+// closeConn closes the connection and stops watching the request context
+func (b *connBody) closeConn() {
+	b.once.Do(func() {
+		close(b.done)
+		b.conn.Close()
+	})
+}
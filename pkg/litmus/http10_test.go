@@ -0,0 +1,53 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTP10(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"proto": r.Proto,
+			"close": r.Close,
+			"tls":   r.TLS != nil,
+		})
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "tls",
+			test: Test{
+				Method:           http.MethodGet,
+				Path:             "/proto",
+				HTTP10:           true,
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: map[string]interface{}{"proto": "HTTP/1.0", "close": true, "tls": true},
+			},
+		},
+	})
+
+	t.Run("plain", func(t *testing.T) {
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		(&Test{
+			Method:           http.MethodGet,
+			Path:             "/proto",
+			HTTP10:           true,
+			ExpectedStatus:   http.StatusOK,
+			ExpectedResponse: map[string]interface{}{"proto": "HTTP/1.0", "close": true, "tls": false},
+		}).DoAgainst(server, &Mock{}, t)
+	})
+}
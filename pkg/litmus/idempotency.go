@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"testing"
+)
+
+const (
+	// IdempotencyKeyHeader is the header used to send the idempotency key
+	IdempotencyKeyHeader = "Idempotency-Key"
+)
+
+// DoIdempotent executes the test twice with the same idempotency key against the same backend,
+// both responses are asserted and each mutating operation, those with Times 1, must be called exactly once in total;
+// other operations, i.e. lookups before the dedupe check, may be called by both requests
+func (t *Test) DoIdempotent(backend Backend, handler http.Handler, key string, tt *testing.T) {
+	tt.Helper()
+
+	setup := t.Setup
+	t.Setup = func(r *http.Request) {
+		if setup != nil {
+			setup(r)
+		}
+		r.Header.Set(IdempotencyKeyHeader, key)
+	}
+	defer func() {
+		t.Setup = setup
+	}()
+
+	tt.Run("first", func(tt *testing.T) {
+		t.do(backend, handler, tt)
+	})
+
+	replay := *t
+	replay.replay = true
+
+	tt.Run("replay", func(tt *testing.T) {
+		replay.do(backend, handler, tt)
+	})
+
+	for _, o := range t.Operations {
+		if o.Times != 1 {
+			continue
+		}
+
+		calls := t.backendCalls(backend)
+		if o.Backend != nil {
+			calls = o.Backend.Calls
+		}
+
+		n := 0
+		for _, c := range calls {
+			if c.Method == o.Name {
+				n++
+			}
+		}
+
+		if n != 1 {
+			tt.Errorf("operation %s called %d times with idempotency key %q, expected 1", o.Name, n, key)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDoIdempotent(t *testing.T) {
+	handler := func(store *userStore, dedupe bool) http.Handler {
+		var lock sync.Mutex
+		seen := make(map[string]bool)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			u, _ := store.Get("u1")
+
+			if key := r.Header.Get(IdempotencyKeyHeader); !dedupe || !seen[key] {
+				store.Save(u)
+				seen[key] = true
+			}
+
+			w.WriteHeader(http.StatusCreated)
+		})
+	}
+
+	cases := []struct {
+		name    string
+		dedupe  bool
+		failure string
+	}{
+		{name: "deduplicated", dedupe: true},
+		{name: "duplicated", failure: "--- FAIL: TestDoIdempotent/duplicated/replay"},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			// the failing replays run in a child process
+			if c.failure != "" && !inSubprocess(t) {
+				if out := subprocess(t); !strings.Contains(out, c.failure) {
+					t.Errorf("expected %q to be reported:\n%s", c.failure, out)
+				}
+				return
+			}
+
+			store := &userStore{}
+
+			(&Test{
+				Operations: []Operation{
+					{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}},
+					{Name: "Save", Args: Args{&user{}}, Returns: Returns{nil}, Times: 1},
+				},
+				Method:         http.MethodPost,
+				Path:           "/orders",
+				Request:        map[string]int{"qty": 1},
+				ExpectedStatus: http.StatusCreated,
+			}).DoIdempotent(&store.Mock, handler(store, c.dedupe), "key-1", t)
+		})
+	}
+}
@@ -0,0 +1,214 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+type (
+	address struct {
+		City string
+	}
+
+	user struct {
+		ID      string
+		Name    string
+		Address address
+		secret  string
+	}
+
+	// userStore is the backend of the test handlers
+	userStore struct {
+		Mock
+	}
+
+	// fakeT records the failures of a test run without failing the test
+	fakeT struct {
+		failures []string
+	}
+
+	failNow struct{}
+
+	// testCase is a table test run by runCases against a fresh store, it passes unless failure is set
+	testCase struct {
+		name string
+		test Test
+
+		// handler replaces the handler of the table
+		handler func(store *userStore) http.Handler
+
+		// failure is expected in the failures of the run
+		failure string
+
+		// check asserts the test and store state after the run
+		check func(tt *testing.T, test *Test, store *userStore)
+	}
+)
+
+const (
+	// subprocessEnv is set to the test name in the child process started by subprocess
+	subprocessEnv = "LITMUS_TEST_SUBPROCESS"
+)
+
+func (s *userStore) Get(id string) (*user, error) {
+	r := s.Called(id)
+	u, _ := r.Get(0).(*user)
+	return u, r.Error(1)
+}
+
+func (s *userStore) List() ([]*user, error) {
+	r := s.Called()
+	l, _ := r.Get(0).([]*user)
+	return l, r.Error(1)
+}
+
+func (s *userStore) Save(u *user) error {
+	return s.Called(u).Error(0)
+}
+
+func (s *userStore) Audit(msg string) {
+	s.Called(msg)
+}
+
+func (s *userStore) Fetch(ctx context.Context, id string) error {
+	return s.Called(ctx, id).Error(0)
+}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) Fatalf(format string, args ...interface{}) {
+	f.Errorf(format, args...)
+	f.FailNow()
+}
+
+func (f *fakeT) FailNow() {
+	panic(failNow{})
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Logf(format string, args ...interface{}) {}
+
+// run executes the test with a fake T and returns the failures
+func run(t *Test, backend Backend, handler http.Handler) []string {
+	f := &fakeT{}
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(failNow); !ok {
+					panic(r)
+				}
+			}
+		}()
+
+		t.do(backend, handler, f)
+	}()
+
+	return f.failures
+}
+
+// runCases runs each case as a subtest, the handler is built around the store of the case
+func runCases(tt *testing.T, handler func(store *userStore) http.Handler, cases []testCase) {
+	tt.Helper()
+
+	for _, c := range cases {
+		c := c
+
+		tt.Run(c.name, func(tt *testing.T) {
+			h := handler
+			if c.handler != nil {
+				h = c.handler
+			}
+
+			store := &userStore{}
+
+			// the cases may share their operations, each run binds its own copy
+			test := c.test
+			test.Operations = append([]Operation(nil), c.test.Operations...)
+
+			if c.failure == "" {
+				test.Do(&store.Mock, h(store), tt)
+			} else {
+				requireFailure(tt, run(&test, &store.Mock, h(store)), c.failure)
+			}
+
+			if c.check != nil {
+				c.check(tt, &test, store)
+			}
+		})
+	}
+}
+
+// stateless returns the handler for any store, for the handlers not calling the backend
+func stateless(h http.Handler) func(store *userStore) http.Handler {
+	return func(*userStore) http.Handler {
+		return h
+	}
+}
+
+// requireFailure fails the test unless one of the failures contains msg
+func requireFailure(tt *testing.T, failures []string, msg string) {
+	tt.Helper()
+
+	for _, f := range failures {
+		if strings.Contains(f, msg) {
+			return
+		}
+	}
+
+	tt.Fatalf("expected a failure containing %q, got %q", msg, failures)
+}
+
+// jsonHandler writes the value as the json response
+func jsonHandler(status int, v interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	})
+}
+
+// inSubprocess returns true in the child process started by subprocess for the test
+func inSubprocess(tt *testing.T) bool {
+	return os.Getenv(subprocessEnv) == tt.Name()
+}
+
+// subprocess runs the top level test in a child test process and returns its verbose output,
+// for tests asserting how failures are reported to the *testing.T
+func subprocess(tt *testing.T) string {
+	tt.Helper()
+
+	return subprocessRun(tt, tt.Name())
+}
+
+// subprocessRun runs the named test or fuzz target in a child test process started for the test
+func subprocessRun(tt *testing.T, name string) string {
+	tt.Helper()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^"+name+"$", "-test.v", "-test.count=1")
+	cmd.Env = append(os.Environ(), subprocessEnv+"="+tt.Name())
+
+	out, err := cmd.CombinedOutput()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		tt.Fatalf("failed to run subprocess: %s", err.Error())
+	}
+
+	return string(out)
+}
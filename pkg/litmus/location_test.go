@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// doAt runs the test, failures must be reported at the Do call below
+func doAt(tt *testing.T, test *Test, backend Backend, handler http.Handler) {
+	test.Do(backend, handler, tt)
+}
+
+func TestCallerLocation(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ID":"1","Name":"ada"}`))
+	})
+
+	cases := map[string]*Test{
+		"status": {
+			Method:         http.MethodGet,
+			Path:           "/users/1",
+			ExpectedStatus: http.StatusCreated,
+		},
+		"response": {
+			Method:           http.MethodGet,
+			Path:             "/users/1",
+			ExpectedStatus:   http.StatusOK,
+			ExpectedResponse: map[string]string{"ID": "1", "Name": "grace"},
+		},
+		"headers": {
+			Method:          http.MethodGet,
+			Path:            "/users/1",
+			ExpectedStatus:  http.StatusOK,
+			ExpectedHeaders: map[string]string{"Content-Type": "text/plain"},
+		},
+		"expectations": {
+			Method:         http.MethodGet,
+			Path:           "/users/1",
+			ExpectedStatus: http.StatusOK,
+			Operations: []Operation{
+				{Name: "Get", Args: Args{"1"}, Returns: Returns{&user{ID: "1"}, nil}},
+			},
+		},
+	}
+
+	if inSubprocess(t) {
+		for name, c := range cases {
+			c := c
+			t.Run(name, func(t *testing.T) {
+				doAt(t, c, &(&userStore{}).Mock, handler)
+			})
+		}
+		return
+	}
+
+	_, line := runtime.FuncForPC(reflect.ValueOf(doAt).Pointer()).FileLine(reflect.ValueOf(doAt).Pointer())
+	location := fmt.Sprintf("location_test.go:%d: ", line+1)
+
+	out := subprocess(t)
+
+	for name := range cases {
+		if !strings.Contains(out, "--- FAIL: TestCallerLocation/"+name) {
+			t.Errorf("case %s did not fail:\n%s", name, out)
+		}
+	}
+
+	if n := strings.Count(out, location); n < len(cases) {
+		t.Errorf("%d failures reported at %s, expected at least %d:\n%s", n, location, len(cases), out)
+	}
+
+	for _, m := range regexp.MustCompile(`(?m)^\s+(\w+\.go:\d+): `).FindAllStringSubmatch(out, -1) {
+		if !strings.HasPrefix(m[1], "location_test.go:") {
+			t.Errorf("failure reported at %s, expected the caller %s", m[1], location)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestMethods(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMultiStatus)
+		json.NewEncoder(w).Encode(map[string]string{"method": r.Method, "depth": r.Header.Get("Depth")})
+	})
+
+	depth := func(r *http.Request) { r.Header.Set("Depth", "1") }
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "webdav",
+			test: Test{
+				Method:           MethodPropfind,
+				Path:             "/files/",
+				Setup:            depth,
+				ExpectedStatus:   http.StatusMultiStatus,
+				ExpectedResponse: map[string]string{"method": MethodPropfind, "depth": "1"},
+			},
+		},
+		{
+			name: "custom",
+			test: Test{
+				Method:           "Purge",
+				Path:             "/files/",
+				Setup:            depth,
+				ExpectedStatus:   http.StatusMultiStatus,
+				ExpectedResponse: map[string]string{"method": "Purge", "depth": "1"},
+			},
+		},
+		{
+			name:    "empty",
+			test:    Test{Path: "/files/", ExpectedStatus: http.StatusMultiStatus},
+			failure: "invalid test: method is required",
+		},
+	})
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+type (
+	// NetworkConditions simulates a slow or unreliable network between the client and the server
+	NetworkConditions struct {
+		// Latency is added before each request is sent
+		Latency time.Duration
+
+		// FailureRate is the probability, from 0 to 1, a request fails with a connection error
+		FailureRate float64
+
+		// Timeout is the client timeout, including the latency
+		Timeout time.Duration
+
+		// Seed seeds the FailureRate random source, runs with the same seed fail the same requests
+		Seed int64
+	}
+
+	// networkTransport applies the network conditions to the wrapped transport
+	networkTransport struct {
+		next       http.RoundTripper
+		conditions *NetworkConditions
+		rand       *rand.Rand
+	}
+)
+
+var (
+	// ErrNetworkFailure is returned for requests failed by the NetworkConditions FailureRate
+	ErrNetworkFailure = errors.New("simulated network failure")
+
+	// ErrClientTimeout matches any client timeout as the ExpectedClientError, i.e. the NetworkConditions Timeout
+	ErrClientTimeout = errors.New("client timeout")
+)
+
+// RoundTrip implements the http.RoundTripper interface
+func (n *networkTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if n.conditions.FailureRate > 0 && n.rand.Float64() < n.conditions.FailureRate {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, ErrNetworkFailure
+	}
+
+	if n.conditions.Latency > 0 {
+		timer := time.NewTimer(n.conditions.Latency)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, req.Context().Err()
+		}
+	}
+
+	return n.next.RoundTrip(req)
+}
+
+// assertClientError checks the request error matches the ExpectedClientError
+func (t *Test) assertClientError(tt testingT, err error) {
+	tt.Helper()
+
+	if errors.Is(err, t.ExpectedClientError) {
+		return
+	}
+
+	var ne net.Error
+	if errors.Is(t.ExpectedClientError, ErrClientTimeout) && errors.As(err, &ne) && ne.Timeout() {
+		return
+	}
+
+	tt.Fatalf("unexpected client error %s, expected %s", err.Error(), t.ExpectedClientError)
+}
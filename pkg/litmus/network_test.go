@@ -0,0 +1,83 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNetworkConditions(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	var start time.Time
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "latency",
+			test: Test{
+				Method:            http.MethodGet,
+				Path:              "/health",
+				Setup:             func(r *http.Request) { start = time.Now() },
+				ExpectedStatus:    http.StatusNoContent,
+				NetworkConditions: &NetworkConditions{Latency: 50 * time.Millisecond, Timeout: time.Second},
+			},
+			check: func(tt *testing.T, test *Test, store *userStore) {
+				if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+					tt.Fatalf("request completed in %s, expected at least the 50ms latency", elapsed)
+				}
+			},
+		},
+		{
+			name: "timeout",
+			test: Test{
+				Method:              http.MethodGet,
+				Path:                "/health",
+				ExpectedStatus:      http.StatusNoContent,
+				NetworkConditions:   &NetworkConditions{Latency: time.Second, Timeout: 50 * time.Millisecond},
+				ExpectedClientError: ErrClientTimeout,
+			},
+		},
+		{
+			name: "failure",
+			test: Test{
+				Method:              http.MethodGet,
+				Path:                "/health",
+				ExpectedStatus:      http.StatusNoContent,
+				NetworkConditions:   &NetworkConditions{FailureRate: 1},
+				ExpectedClientError: ErrNetworkFailure,
+			},
+		},
+		{
+			name: "within timeout",
+			test: Test{
+				Method:              http.MethodGet,
+				Path:                "/health",
+				ExpectedStatus:      http.StatusNoContent,
+				NetworkConditions:   &NetworkConditions{Latency: 10 * time.Millisecond, Timeout: time.Second},
+				ExpectedClientError: ErrClientTimeout,
+			},
+			failure: "request succeeded with status 204, expected client error client timeout",
+		},
+		{
+			name: "unexpected timeout",
+			test: Test{
+				Method:              http.MethodGet,
+				Path:                "/health",
+				ExpectedStatus:      http.StatusNoContent,
+				NetworkConditions:   &NetworkConditions{Latency: time.Second, Timeout: 50 * time.Millisecond},
+				ExpectedClientError: ErrNetworkFailure,
+			},
+			failure: "expected simulated network failure",
+		},
+	})
+}
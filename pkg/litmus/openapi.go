@@ -0,0 +1,370 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// openAPISpec is the subset of an OpenAPI 3 document used to validate requests
+	openAPISpec struct {
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+		Paths      map[string]*openAPIPath `json:"paths"`
+		Components struct {
+			Schemas map[string]*openAPISchema `json:"schemas"`
+		} `json:"components"`
+	}
+
+	openAPIPath struct {
+		Parameters []openAPIParameter `json:"parameters"`
+		Get        *openAPIOperation  `json:"get"`
+		Put        *openAPIOperation  `json:"put"`
+		Post       *openAPIOperation  `json:"post"`
+		Delete     *openAPIOperation  `json:"delete"`
+		Options    *openAPIOperation  `json:"options"`
+		Head       *openAPIOperation  `json:"head"`
+		Patch      *openAPIOperation  `json:"patch"`
+		Trace      *openAPIOperation  `json:"trace"`
+	}
+
+	openAPIOperation struct {
+		Parameters  []openAPIParameter `json:"parameters"`
+		RequestBody *struct {
+			Required bool `json:"required"`
+			Content  map[string]struct {
+				Schema *openAPISchema `json:"schema"`
+			} `json:"content"`
+		} `json:"requestBody"`
+	}
+
+	openAPIParameter struct {
+		Name     string `json:"name"`
+		In       string `json:"in"`
+		Required bool   `json:"required"`
+	}
+
+	openAPISchema struct {
+		Ref        string                    `json:"$ref"`
+		Type       string                    `json:"type"`
+		Required   []string                  `json:"required"`
+		Properties map[string]*openAPISchema `json:"properties"`
+		Items      *openAPISchema            `json:"items"`
+		Enum       []interface{}             `json:"enum"`
+	}
+)
+
+// OpenAPI loads the yaml or json OpenAPI 3 spec the suite test requests are validated against before they are sent,
+// the test path is matched to the spec paths with or without the test base path or a spec server url path, and the
+// method, required query parameters and json body are checked
+func (s *Suite) OpenAPI(specPath string) error {
+	data, err := ioutil.ReadFile(specPath)
+	if err != nil {
+		return err
+	}
+
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse openapi spec: %w", err)
+	}
+
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to parse openapi spec: %w", err)
+	}
+
+	spec := &openAPISpec{}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return fmt.Errorf("failed to parse openapi spec: %w", err)
+	}
+
+	s.spec = spec
+
+	return nil
+}
+
+// validate checks the test request against the spec
+func (s *openAPISpec) validate(t *Test) error {
+	if t.RequestObject != nil {
+		return nil
+	}
+
+	item := s.matchRequest(t)
+	if item == nil {
+		return fmt.Errorf("path %s not found", t.BasePath+t.Path)
+	}
+
+	op := item.operation(t.Method)
+	if op == nil {
+		return fmt.Errorf("method %s not allowed for %s", t.Method, t.Path)
+	}
+
+	for _, p := range append(item.Parameters, op.Parameters...) {
+		if p.In == "query" && p.Required && t.Query.Get(p.Name) == "" {
+			return fmt.Errorf("%s %s: required query parameter %s missing", t.Method, t.Path, p.Name)
+		}
+	}
+
+	if op.RequestBody == nil {
+		return nil
+	}
+
+	body, ok, err := t.requestJSON()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", t.Method, t.Path, err)
+	}
+
+	if !ok {
+		if op.RequestBody.Required && t.Request == nil {
+			return fmt.Errorf("%s %s: request body required", t.Method, t.Path)
+		}
+		return nil
+	}
+
+	for ct, c := range op.RequestBody.Content {
+		if !isJSONContentType(ct) || c.Schema == nil {
+			continue
+		}
+		if err := s.validateSchema(c.Schema, body, "body"); err != nil {
+			return fmt.Errorf("%s %s: %w", t.Method, t.Path, err)
+		}
+		break
+	}
+
+	return nil
+}
+
+// matchRequest returns the path item matching the request path as sent, without a spec server url path or
+// without the test base path
+func (s *openAPISpec) matchRequest(t *Test) *openAPIPath {
+	path := t.BasePath + t.Path
+
+	if item := s.match(path); item != nil {
+		return item
+	}
+
+	for _, srv := range s.Servers {
+		u, err := url.Parse(srv.URL)
+		if err != nil {
+			continue
+		}
+
+		base := strings.TrimSuffix(u.Path, "/")
+		if base == "" || !strings.HasPrefix(path, base+"/") {
+			continue
+		}
+
+		if item := s.match(strings.TrimPrefix(path, base)); item != nil {
+			return item
+		}
+	}
+
+	if t.BasePath != "" {
+		return s.match(t.Path)
+	}
+
+	return nil
+}
+
+// match returns the path item matching the request path, template segments match any value
+func (s *openAPISpec) match(path string) *openAPIPath {
+	if item, ok := s.Paths[path]; ok {
+		return item
+	}
+
+	keys := make([]string, 0, len(s.Paths))
+	for k := range s.Paths {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+
+	for _, k := range keys {
+		tmpl := strings.Split(strings.Trim(k, "/"), "/")
+		if len(tmpl) != len(segs) {
+			continue
+		}
+
+		matched := true
+		for i, seg := range tmpl {
+			if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+				if segs[i] == "" {
+					matched = false
+					break
+				}
+				continue
+			}
+			if seg != segs[i] {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return s.Paths[k]
+		}
+	}
+
+	return nil
+}
+
+// operation returns the path item operation for the method
+func (p *openAPIPath) operation(method string) *openAPIOperation {
+	switch strings.ToUpper(method) {
+	case http.MethodGet:
+		return p.Get
+	case http.MethodPut:
+		return p.Put
+	case http.MethodPost:
+		return p.Post
+	case http.MethodDelete:
+		return p.Delete
+	case http.MethodOptions:
+		return p.Options
+	case http.MethodHead:
+		return p.Head
+	case http.MethodPatch:
+		return p.Patch
+	case http.MethodTrace:
+		return p.Trace
+	}
+	return nil
+}
+
+// validateSchema checks the decoded json value against the schema
+func (s *openAPISpec) validateSchema(schema *openAPISchema, v interface{}, path string) error {
+	for schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		ref, ok := s.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("%s: unresolved schema reference %s", path, schema.Ref)
+		}
+		schema = ref
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, e := range schema.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value %v not in enum", path, v)
+		}
+	}
+
+	switch schema.Type {
+	case "object":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		for _, r := range schema.Required {
+			if _, ok := m[r]; !ok {
+				return fmt.Errorf("%s: required field %s missing", path, r)
+			}
+		}
+		for k, p := range schema.Properties {
+			if val, ok := m[k]; ok {
+				if err := s.validateSchema(p, val, joinPath(path, k)); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		a, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		if schema.Items != nil {
+			for i, val := range a {
+				if err := s.validateSchema(schema.Items, val, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: expected number", path)
+		}
+	case "integer":
+		if f, ok := v.(float64); !ok || f != math.Trunc(f) {
+			return fmt.Errorf("%s: expected integer", path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean", path)
+		}
+	}
+
+	return nil
+}
+
+// requestJSON returns the decoded json request body, false if the body is not static json
+func (t *Test) requestJSON() (interface{}, bool, error) {
+	var data []byte
+
+	switch m := t.Request.(type) {
+	case nil, RequestHandler, chan []byte, <-chan []byte:
+		return nil, false, nil
+	case []byte:
+		data = m
+	case string:
+		data = []byte(m)
+	case *OperationRef:
+		v, err := m.resolve(t.Operations[m.Index].Args[m.Arg])
+		if err != nil {
+			return nil, false, err
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, false, err
+		}
+		data = b
+	default:
+		b, err := json.Marshal(m)
+		if err != nil {
+			return nil, false, err
+		}
+		data = b
+	}
+
+	if t.RequestContentType != "" && !isJSONContentType(t.RequestContentType) {
+		return nil, false, nil
+	}
+
+	v, err := decodeJSON(string(data), false)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid json request: %w", err)
+	}
+
+	return v, true, nil
+}
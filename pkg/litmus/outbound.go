@@ -0,0 +1,84 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	// OutboundCapture is a http.RoundTripper that records the outbound requests of the handler, the handler
+	// must accept an injectable http client or transport, i.e. the one returned by Client
+	OutboundCapture struct {
+		next     http.RoundTripper
+		requests []*http.Request
+		lock     sync.Mutex
+	}
+)
+
+// CaptureOutbound returns a new outbound capture sending requests with the transport, or http.DefaultTransport if nil
+func CaptureOutbound(next http.RoundTripper) *OutboundCapture {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &OutboundCapture{
+		next: next,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (c *OutboundCapture) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.lock.Lock()
+	c.requests = append(c.requests, req.Clone(req.Context()))
+	c.lock.Unlock()
+
+	return c.next.RoundTrip(req)
+}
+
+// Client returns a http client using the capture as its transport
+func (c *OutboundCapture) Client() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+// Requests returns the captured outbound requests
+func (c *OutboundCapture) Requests() []*http.Request {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]*http.Request{}, c.requests...)
+}
+
+// Reset discards the captured requests
+func (c *OutboundCapture) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.requests = nil
+}
+
+// assertOutbound checks the ExpectedOutboundHeaders of each captured request
+func (t *Test) assertOutbound(tt testingT) {
+	tt.Helper()
+
+	reqs := t.OutboundCapture.Requests()
+
+	if len(t.ExpectedOutboundHeaders) > 0 && len(reqs) == 0 {
+		tt.Errorf("no outbound requests captured")
+	}
+
+	for _, r := range reqs {
+		for k, v := range t.ExpectedOutboundHeaders {
+			require.Regexp(tt, v, r.Header.Get(k), "unexpected outbound header %s to %s", k, r.URL)
+		}
+	}
+}
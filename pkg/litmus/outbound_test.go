@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestOutboundCapture(t *testing.T) {
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer downstream.Close()
+
+	capture := CaptureOutbound(nil)
+
+	handler := func(forward, call bool) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if call {
+				req, _ := http.NewRequest(http.MethodGet, downstream.URL+"/quotes", nil)
+				if forward {
+					req.Header.Set("Grpc-Timeout", r.URL.Query().Get("timeout"))
+				}
+
+				resp, err := capture.Client().Do(req)
+				if err != nil {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				resp.Body.Close()
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	}
+
+	test := Test{
+		Method:                  http.MethodGet,
+		Path:                    "/quotes",
+		Query:                   url.Values{"timeout": []string{"250m"}},
+		ExpectedStatus:          http.StatusNoContent,
+		OutboundCapture:         capture,
+		ExpectedOutboundHeaders: map[string]string{"Grpc-Timeout": `^\d+m$`},
+	}
+
+	runCases(t, handler(true, true), []testCase{
+		{
+			name: "forwarded",
+			test: test,
+			check: func(tt *testing.T, test *Test, store *userStore) {
+				if reqs := capture.Requests(); len(reqs) != 1 || reqs[0].Header.Get("Grpc-Timeout") != "250m" {
+					tt.Fatalf("unexpected outbound requests %v", reqs)
+				}
+			},
+		},
+		{
+			name:    "not forwarded",
+			test:    test,
+			handler: handler(false, true),
+			failure: "unexpected outbound header Grpc-Timeout",
+		},
+		{
+			name:    "no requests",
+			test:    test,
+			handler: handler(false, false),
+			failure: "no outbound requests captured",
+		},
+	})
+}
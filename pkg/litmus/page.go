@@ -0,0 +1,80 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+)
+
+type (
+	// Page is an expected {"data": [...], "next": "...", "total": N} pagination envelope
+	Page struct {
+		// Total is the expected total, zero expects the number of data items
+		Total int
+
+		// Data is compared with the data array if set
+		Data interface{}
+
+		// Last asserts next is null
+		Last bool
+	}
+)
+
+// assertPage checks the pagination envelope keys and types, and the data if set
+func (t *Test) assertPage(tt testingT, body []byte) {
+	tt.Helper()
+
+	var env map[string]json.RawMessage
+
+	if err := json.Unmarshal(body, &env); err != nil {
+		tt.Fatalf("failed to decode page: %s", err.Error())
+	}
+
+	for _, k := range []string{"data", "next", "total"} {
+		if _, ok := env[k]; !ok {
+			tt.Fatalf("page key %s missing", k)
+		}
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(env["data"], &items); err != nil || items == nil {
+		tt.Fatalf("page data is not an array: %s", string(env["data"]))
+	}
+
+	var total int
+	if err := json.Unmarshal(env["total"], &total); err != nil {
+		tt.Fatalf("page total is not an integer: %s", string(env["total"]))
+	}
+
+	expected := t.ExpectedPage.Total
+	if expected == 0 {
+		expected = len(items)
+	}
+
+	if total != expected {
+		tt.Errorf("page total %d, expected %d", total, expected)
+	}
+
+	var next *string
+	if err := json.Unmarshal(env["next"], &next); err != nil {
+		tt.Fatalf("page next is not a string or null: %s", string(env["next"]))
+	}
+
+	if t.ExpectedPage.Last && next != nil {
+		tt.Errorf("page next %q, expected null", *next)
+	}
+
+	if t.ExpectedPage.Data != nil {
+		data, err := json.Marshal(t.ExpectedPage.Data)
+		if err != nil {
+			tt.Fatalf("%s", marshalError("ExpectedPage.Data", t.ExpectedPage.Data, err).Error())
+		}
+		t.jsonEq(tt, string(data), string(env["data"]))
+	}
+}
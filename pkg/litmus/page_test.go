@@ -0,0 +1,87 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestExpectedPage(t *testing.T) {
+	handler := func(body string) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}))
+	}
+
+	page := func(p *Page) Test {
+		return Test{
+			Method:         http.MethodGet,
+			Path:           "/users",
+			ExpectedStatus: http.StatusOK,
+			ExpectedPage:   p,
+		}
+	}
+
+	runCases(t, nil, []testCase{
+		{
+			name:    "first",
+			test:    page(&Page{Data: []user{{ID: "1", Name: "ada"}, {ID: "2", Name: "grace"}}}),
+			handler: handler(`{"data":[{"ID":"1","Name":"ada","Address":{"City":""}},{"ID":"2","Name":"grace","Address":{"City":""}}],"next":"c2","total":2}`),
+		},
+		{
+			name:    "last",
+			test:    page(&Page{Total: 12, Last: true}),
+			handler: handler(`{"data":[{"ID":"12","Name":"linus","Address":{"City":""}}],"next":null,"total":12}`),
+		},
+		{
+			name:    "missing key",
+			test:    page(&Page{}),
+			handler: handler(`{"data":[],"total":0}`),
+			failure: "page key next missing",
+		},
+		{
+			name:    "data object",
+			test:    page(&Page{}),
+			handler: handler(`{"data":{},"next":null,"total":0}`),
+			failure: "page data is not an array: {}",
+		},
+		{
+			name:    "total string",
+			test:    page(&Page{}),
+			handler: handler(`{"data":[],"next":null,"total":"0"}`),
+			failure: `page total is not an integer: "0"`,
+		},
+		{
+			name:    "total mismatch",
+			test:    page(&Page{}),
+			handler: handler(`{"data":[{}],"next":null,"total":3}`),
+			failure: "page total 3, expected 1",
+		},
+		{
+			name:    "next number",
+			test:    page(&Page{}),
+			handler: handler(`{"data":[],"next":1,"total":0}`),
+			failure: "page next is not a string or null: 1",
+		},
+		{
+			name:    "not last",
+			test:    page(&Page{Last: true}),
+			handler: handler(`{"data":[],"next":"c3","total":0}`),
+			failure: `page next "c3", expected null`,
+		},
+		{
+			name:    "data",
+			test:    page(&Page{Data: []user{{ID: "1", Name: "grace"}}}),
+			handler: handler(`{"data":[{"ID":"1","Name":"ada","Address":{"City":""}}],"next":null,"total":1}`),
+			failure: `$[0].Name: expected "grace", got "ada"`,
+		},
+	})
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+)
+
+type (
+	// PatchOp is a RFC 6902 json patch operation
+	PatchOp struct {
+		// Op is the operation: add, remove, replace, move, copy or test
+		Op string
+
+		// Path is the target json pointer
+		Path string
+
+		// From is the source json pointer for move and copy
+		From string
+
+		// Value is the operation value for add, replace and test
+		Value interface{}
+	}
+
+	mergePatch map[string]interface{}
+
+	jsonPatch []PatchOp
+)
+
+const (
+	// MergePatchContentType is the RFC 7396 json merge patch content type
+	MergePatchContentType = "application/merge-patch+json"
+
+	// JSONPatchContentType is the RFC 6902 json patch content type
+	JSONPatchContentType = "application/json-patch+json"
+)
+
+// MergePatch returns a json merge patch request body
+func MergePatch(patch map[string]interface{}) interface{} {
+	return mergePatch(patch)
+}
+
+// JSONPatch returns a json patch request body
+func JSONPatch(ops ...PatchOp) interface{} {
+	return jsonPatch(ops)
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (p PatchOp) MarshalJSON() ([]byte, error) {
+	op := map[string]interface{}{
+		"op":   p.Op,
+		"path": p.Path,
+	}
+
+	switch p.Op {
+	case "add", "replace", "test":
+		op["value"] = p.Value
+	case "move", "copy":
+		op["from"] = p.From
+	}
+
+	return json.Marshal(op)
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// patchHandler applies merge and json patches to a flat document
+func patchHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc := map[string]interface{}{"name": "a", "email": "a@example.com", "active": true}
+
+		switch r.Header.Get("Content-Type") {
+		case MergePatchContentType:
+			var patch map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			for k, v := range patch {
+				if v == nil {
+					delete(doc, k)
+				} else {
+					doc[k] = v
+				}
+			}
+
+		case JSONPatchContentType:
+			var ops []struct {
+				Op    string      `json:"op"`
+				Path  string      `json:"path"`
+				Value interface{} `json:"value"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			for _, op := range ops {
+				key := strings.TrimPrefix(op.Path, "/")
+				switch op.Op {
+				case "add", "replace":
+					doc[key] = op.Value
+				case "remove":
+					delete(doc, key)
+				default:
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					return
+				}
+			}
+
+		default:
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(doc)
+	})
+}
+
+func TestMergePatch(t *testing.T) {
+	test := &Test{
+		Method:           http.MethodPatch,
+		Path:             "/users/1",
+		Request:          MergePatch(map[string]interface{}{"email": nil, "name": "b"}),
+		ExpectedStatus:   http.StatusOK,
+		ExpectedResponse: map[string]interface{}{"name": "b", "active": true},
+	}
+	test.Do(&Mock{}, patchHandler(), t)
+
+	if test.RequestContentType != "" {
+		t.Fatalf("test changed, RequestContentType %s", test.RequestContentType)
+	}
+
+	// the reused test picks the content type of the new patch
+	test.Request = JSONPatch(PatchOp{Op: "replace", Path: "/name", Value: "b"}, PatchOp{Op: "remove", Path: "/email"})
+	test.Do(&Mock{}, patchHandler(), t)
+}
+
+func TestJSONPatch(t *testing.T) {
+	runCases(t, stateless(patchHandler()), []testCase{
+		{
+			name: "patched",
+			test: Test{
+				Method: http.MethodPatch,
+				Path:   "/users/1",
+				Request: JSONPatch(
+					PatchOp{Op: "replace", Path: "/active", Value: false},
+					PatchOp{Op: "remove", Path: "/email"},
+				),
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: map[string]interface{}{"name": "a", "active": false},
+			},
+		},
+		{
+			name: "unsupported op",
+			test: Test{
+				Method:         http.MethodPatch,
+				Path:           "/users/1",
+				Request:        JSONPatch(PatchOp{Op: "move", Path: "/name", From: "/email"}),
+				ExpectedStatus: http.StatusOK,
+			},
+			failure: "actual  : 422",
+		},
+	})
+}
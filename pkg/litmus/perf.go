@@ -0,0 +1,48 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// assertPerf compares the request duration to the baseline file, rewriting it when Update is set
+func (t *Test) assertPerf(tt testingT, elapsed time.Duration) {
+	tt.Helper()
+
+	if Update {
+		if err := os.MkdirAll(filepath.Dir(t.PerfBaseline), 0755); err != nil {
+			tt.Fatalf("failed to create perf baseline directory: %s", err.Error())
+		}
+		if err := ioutil.WriteFile(t.PerfBaseline, []byte(elapsed.String()+"\n"), 0644); err != nil {
+			tt.Fatalf("failed to update perf baseline: %s", err.Error())
+		}
+		return
+	}
+
+	data, err := ioutil.ReadFile(t.PerfBaseline)
+	if err != nil {
+		tt.Fatalf("failed to read perf baseline: %s", err.Error())
+	}
+
+	baseline, err := time.ParseDuration(strings.TrimSpace(string(data)))
+	if err != nil {
+		tt.Fatalf("failed to parse perf baseline: %s", err.Error())
+	}
+
+	limit := time.Duration(float64(baseline) * (1 + t.PerfTolerance))
+
+	if elapsed > limit {
+		tt.Errorf("request took %s, regressed over baseline %s with tolerance %.0f%%", elapsed, baseline, t.PerfTolerance*100)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPerfBaseline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "litmus")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	baseline := filepath.Join(dir, "perf", "users.txt")
+
+	cases := []struct {
+		name    string
+		delay   time.Duration
+		update  bool
+		failure string
+	}{
+		{name: "update", delay: 20 * time.Millisecond, update: true},
+		{name: "within baseline"},
+		{name: "regression", delay: 200 * time.Millisecond, failure: "regressed over baseline"},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			test := &Test{
+				Method:         http.MethodGet,
+				Path:           "/users",
+				ExpectedStatus: http.StatusNoContent,
+				PerfBaseline:   baseline,
+				PerfTolerance:  0.5,
+			}
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(c.delay)
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			if c.failure != "" {
+				requireFailure(t, run(test, &Mock{}, handler), c.failure)
+				return
+			}
+
+			Update = c.update
+			defer func() { Update = false }()
+
+			test.Do(&Mock{}, handler, t)
+
+			if !c.update {
+				return
+			}
+
+			data, err := ioutil.ReadFile(baseline)
+			if err != nil {
+				t.Fatalf("failed to read perf baseline: %s", err.Error())
+			}
+
+			if d, err := time.ParseDuration(strings.TrimSpace(string(data))); err != nil || d < c.delay {
+				t.Fatalf("unexpected perf baseline %q", data)
+			}
+		})
+	}
+}
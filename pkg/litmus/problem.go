@@ -0,0 +1,69 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	// Problem is a RFC 7807 problem details object, only set fields are compared
+	Problem struct {
+		// Type is the problem type uri
+		Type string `json:"type,omitempty"`
+
+		// Title is the short summary of the problem type
+		Title string `json:"title,omitempty"`
+
+		// Status is the http status code
+		Status int `json:"status,omitempty"`
+
+		// Detail is the occurrence specific explanation
+		Detail string `json:"detail,omitempty"`
+
+		// Instance is the occurrence specific uri
+		Instance string `json:"instance,omitempty"`
+	}
+)
+
+const (
+	// ProblemContentType is the RFC 7807 json problem content type
+	ProblemContentType = "application/problem+json"
+)
+
+// assertProblem compares the set fields of the expected problem to the response body
+func (t *Test) assertProblem(tt testingT, data []byte) {
+	tt.Helper()
+
+	var p Problem
+
+	if err := json.Unmarshal(data, &p); err != nil {
+		tt.Fatalf("failed to unmarshal problem response: %s", err.Error())
+	}
+
+	e := t.ExpectedProblem
+
+	if e.Type != "" {
+		require.Equal(tt, e.Type, p.Type, "problem type")
+	}
+	if e.Title != "" {
+		require.Equal(tt, e.Title, p.Title, "problem title")
+	}
+	if e.Status != 0 {
+		require.Equal(tt, e.Status, p.Status, "problem status")
+	}
+	if e.Detail != "" {
+		require.Equal(tt, e.Detail, p.Detail, "problem detail")
+	}
+	if e.Instance != "" {
+		require.Equal(tt, e.Instance, p.Instance, "problem instance")
+	}
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestExpectedProblem(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ProblemContentType)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"type":"https://example.com/probs/not-found","title":"Not Found","status":404,"detail":"user u9 not found","trace_id":"f00"}`))
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "match",
+			test: Test{
+				Method:              http.MethodGet,
+				Path:                "/users/u9",
+				ExpectedStatus:      http.StatusNotFound,
+				ExpectedContentType: ProblemContentType,
+				ExpectedProblem:     &Problem{Type: "https://example.com/probs/not-found", Title: "Not Found", Status: http.StatusNotFound},
+			},
+		},
+		{
+			name: "mismatch",
+			test: Test{
+				Method:              http.MethodGet,
+				Path:                "/users/u9",
+				ExpectedStatus:      http.StatusNotFound,
+				ExpectedContentType: ProblemContentType,
+				ExpectedProblem:     &Problem{Title: "Gone", Status: http.StatusNotFound},
+			},
+			failure: "problem title",
+		},
+	})
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"io/ioutil"
+	"net/http"
+	"regexp"
+)
+
+// Record executes the request against a live server and returns a test fixture of the
+// request and response, it is a development helper for bootstrapping tests
+func Record(client *http.Client, req *http.Request) (*Test, error) {
+	t := &Test{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.Query(),
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(data) > 0 {
+			t.Request = string(data)
+			t.RequestContentType = req.Header.Get("Content-Type")
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	ct := resp.Header.Get("Content-Type")
+
+	t.ExpectedStatus = resp.StatusCode
+	t.ExpectedContentType = ct
+
+	switch {
+	case len(data) == 0:
+		// no body to compare
+	case isJSONContentType(ct), isXMLContentType(ct):
+		t.ExpectedResponse = string(data)
+	case isTextContentType(ct):
+		t.ExpectedResponse = regexp.MustCompile("^" + regexp.QuoteMeta(string(data)) + "$")
+	default:
+		t.ExpectedResponse = data
+	}
+
+	return t, nil
+}
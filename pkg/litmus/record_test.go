@@ -0,0 +1,60 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	handler := func(city string) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var u user
+			json.NewDecoder(r.Body).Decode(&u)
+
+			u.ID = r.URL.Query().Get("id")
+			u.Address.City = city
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(u)
+		}))
+	}
+
+	upstream := httptest.NewServer(handler("Paris")(nil))
+	defer upstream.Close()
+
+	req, err := http.NewRequest(http.MethodPost, upstream.URL+"/users?id=u1", strings.NewReader(`{"Name":"Ann"}`))
+	if err != nil {
+		t.Fatalf("failed to create request: %s", err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	test, err := Record(upstream.Client(), req)
+	if err != nil {
+		t.Fatalf("failed to record request: %s", err.Error())
+	}
+
+	if test.Method != http.MethodPost || test.Path != "/users" || test.Query.Get("id") != "u1" || test.Request != `{"Name":"Ann"}` {
+		t.Errorf("unexpected recorded request %s %s?%s %v", test.Method, test.Path, test.Query.Encode(), test.Request)
+	}
+
+	if test.ExpectedStatus != http.StatusCreated || test.ExpectedContentType != "application/json" {
+		t.Errorf("unexpected recorded response %d %s", test.ExpectedStatus, test.ExpectedContentType)
+	}
+
+	runCases(t, handler("Paris"), []testCase{
+		{name: "replayed", test: *test},
+		{name: "changed", test: *test, handler: handler("Lyon"), failure: `$.Address.City: expected "Paris", got "Lyon"`},
+	})
+}
@@ -0,0 +1,31 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+// Recorded returns the arguments of the backend calls to the operation method with the operation arg count, in
+// call order; the args are not matched again so matchers, i.e. ContextCapture, are not re-run
+func (o Operation) Recorded() [][]interface{} {
+	if o.call == nil || o.call.Parent == nil {
+		return nil
+	}
+
+	recorded := make([][]interface{}, 0)
+
+	for _, c := range o.call.Parent.Calls {
+		if c.Method != o.Name {
+			continue
+		}
+		if len(c.Arguments) != len(o.call.Arguments) {
+			continue
+		}
+		recorded = append(recorded, []interface{}(c.Arguments))
+	}
+
+	return recorded
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+
+	"github.com/stretchr/testify/require"
+)
+
+// assertReference replays the request against the ReferenceHandler and compares the responses
+func (t *Test) assertReference(tt testingT, req *http.Request, resp *http.Response, data []byte) {
+	tt.Helper()
+
+	ts := httptest.NewTLSServer(t.ReferenceHandler)
+	defer ts.Close()
+
+	r, err := replayRequest(req)
+	if err != nil {
+		tt.Fatalf("failed to replay reference request: %s", err.Error())
+	}
+
+	u, _ := url.Parse(ts.URL)
+	if r.Host == r.URL.Host {
+		r.Host = u.Host
+	}
+	r.URL.Scheme = u.Scheme
+	r.URL.Host = u.Host
+
+	client := ts.Client()
+	client.CheckRedirect = t.checkRedirect()
+
+	ref, err := client.Do(r)
+	if err != nil {
+		tt.Fatalf("failed to execute reference request: %s", err.Error())
+	}
+	defer ref.Body.Close()
+
+	refData, err := ioutil.ReadAll(ref.Body)
+	if err != nil {
+		tt.Fatalf("failed to read reference response: %s", err.Error())
+	}
+
+	require.Equal(tt, ref.StatusCode, resp.StatusCode, "status differs from reference")
+	require.Equal(tt, referenceHeaders(ref.Header), referenceHeaders(resp.Header), "headers differ from reference")
+
+	ct := ref.Header.Get("Content-Type")
+
+	switch {
+	case len(refData) == 0 || len(data) == 0:
+		require.Equal(tt, string(refData), string(data), "body differs from reference")
+	case isJSONContentType(ct):
+		t.jsonEq(tt, string(refData), string(data))
+	case isXMLContentType(ct):
+		xmlEq(tt, string(refData), string(data))
+	default:
+		require.Equal(tt, string(refData), string(data), "body differs from reference")
+	}
+}
+
+// referenceHeaders returns the header names and values compared with the reference, Date varies per response
+// and Content-Length with the body formatting
+func referenceHeaders(h http.Header) map[string][]string {
+	m := make(map[string][]string)
+
+	for k, v := range h {
+		if k == "Date" || k == "Content-Length" {
+			continue
+		}
+		vals := append([]string{}, v...)
+		sort.Strings(vals)
+		m[k] = vals
+	}
+
+	return m
+}
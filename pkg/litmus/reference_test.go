@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestReferenceHandler(t *testing.T) {
+	legacy := jsonHandler(http.StatusOK, &user{ID: "u1", Name: "Ann", Address: address{City: "Paris"}})
+
+	// the migrated handler formats the same document differently
+	migrated := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Address": {"City": "Paris"}, "Name": "Ann", "ID": "u1"}`))
+	})
+
+	cached := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		legacy.ServeHTTP(w, r)
+	})
+
+	accepted := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(&user{ID: "u1", Name: "Ann", Address: address{City: "Paris"}})
+	})
+
+	test := Test{
+		Method:           http.MethodGet,
+		Path:             "/users/u1",
+		ExpectedStatus:   http.StatusOK,
+		ReferenceHandler: legacy,
+	}
+
+	runCases(t, stateless(migrated), []testCase{
+		{
+			name: "equivalent",
+			test: test,
+		},
+		{
+			name:    "body differs",
+			test:    test,
+			handler: stateless(jsonHandler(http.StatusOK, &user{ID: "u1", Name: "Ann", Address: address{City: "Lyon"}})),
+			failure: `$.Address.City: expected "Paris", got "Lyon"`,
+		},
+		{
+			name:    "headers differ",
+			test:    test,
+			handler: stateless(cached),
+			failure: "headers differ from reference",
+		},
+		{
+			name: "status differs",
+			test: Test{
+				Method:           http.MethodGet,
+				Path:             "/users/u1",
+				ExpectedStatus:   http.StatusAccepted,
+				ReferenceHandler: legacy,
+			},
+			handler: stateless(accepted),
+			failure: "status differs from reference",
+		},
+	})
+}
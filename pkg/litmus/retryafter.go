@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// DurationRange is an inclusive range of durations
+	DurationRange struct {
+		// Min is the minimum duration
+		Min time.Duration
+
+		// Max is the maximum duration
+		Max time.Duration
+	}
+)
+
+// RetryAfter returns an expected Retry-After range, within one second of the duration to allow for http date precision
+func RetryAfter(d time.Duration) *DurationRange {
+	return &DurationRange{Min: d - time.Second, Max: d + time.Second}
+}
+
+// assertRetryAfter checks the Retry-After header, delay seconds or http date, is within the expected range
+func (t *Test) assertRetryAfter(tt testingT, h http.Header) {
+	tt.Helper()
+
+	v := h.Get("Retry-After")
+	if v == "" {
+		tt.Fatalf("response header Retry-After missing")
+	}
+
+	now := time.Now()
+	if date, err := http.ParseTime(h.Get("Date")); err == nil {
+		now = date
+	}
+
+	d, err := parseRetryAfter(v, now)
+	if err != nil {
+		tt.Fatalf("failed to parse Retry-After: %s", err.Error())
+	}
+
+	if d < t.ExpectedRetryAfter.Min || d > t.ExpectedRetryAfter.Max {
+		tt.Errorf("Retry-After %q is %s, expected within [%s, %s]", v, d, t.ExpectedRetryAfter.Min, t.ExpectedRetryAfter.Max)
+	}
+}
+
+// parseRetryAfter returns the delay of the Retry-After value relative to now
+func parseRetryAfter(v string, now time.Time) (time.Duration, error) {
+	v = strings.TrimSpace(v)
+
+	if secs, err := strconv.ParseUint(v, 10, 63); err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+
+	at, err := http.ParseTime(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid delay seconds or http date %q", v)
+	}
+
+	return at.Sub(now), nil
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestExpectedRetryAfter(t *testing.T) {
+	handler := func(value func() string) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", value())
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+	}
+
+	seconds := handler(func() string { return "120" })
+	date := handler(func() string { return time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat) })
+
+	runCases(t, seconds, []testCase{
+		{
+			name: "range",
+			test: Test{
+				Method:             http.MethodGet,
+				Path:               "/",
+				ExpectedStatus:     http.StatusServiceUnavailable,
+				ExpectedRetryAfter: &DurationRange{Min: time.Minute, Max: 3 * time.Minute},
+			},
+		},
+		{
+			name: "seconds",
+			test: Test{
+				Method:             http.MethodGet,
+				Path:               "/",
+				ExpectedStatus:     http.StatusServiceUnavailable,
+				ExpectedRetryAfter: RetryAfter(2 * time.Minute),
+			},
+		},
+		{
+			name: "date",
+			test: Test{
+				Method:             http.MethodGet,
+				Path:               "/",
+				ExpectedStatus:     http.StatusServiceUnavailable,
+				ExpectedRetryAfter: RetryAfter(30 * time.Second),
+			},
+			handler: date,
+		},
+		{
+			name: "seconds out of range",
+			test: Test{
+				Method:             http.MethodGet,
+				Path:               "/",
+				ExpectedStatus:     http.StatusServiceUnavailable,
+				ExpectedRetryAfter: RetryAfter(10 * time.Second),
+			},
+			failure: `Retry-After "120" is 2m0s, expected within`,
+		},
+		{
+			name: "date out of range",
+			test: Test{
+				Method:             http.MethodGet,
+				Path:               "/",
+				ExpectedStatus:     http.StatusServiceUnavailable,
+				ExpectedRetryAfter: &DurationRange{Min: time.Minute, Max: 2 * time.Minute},
+			},
+			handler: date,
+			failure: "expected within [1m0s, 2m0s]",
+		},
+		{
+			name: "invalid",
+			test: Test{
+				Method:             http.MethodGet,
+				Path:               "/",
+				ExpectedStatus:     http.StatusServiceUnavailable,
+				ExpectedRetryAfter: RetryAfter(time.Minute),
+			},
+			handler: handler(func() string { return "soon" }),
+			failure: `failed to parse Retry-After: invalid delay seconds or http date "soon"`,
+		},
+	})
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"github.com/stretchr/testify/mock"
+)
+
+type (
+	// MockState is a snapshot of the mock expectations and recorded calls
+	MockState struct {
+		expectedCalls []*mock.Call
+		states        []mock.Call
+		calls         []mock.Call
+	}
+)
+
+// Snapshot records the mock expectations, including their call counts, and the recorded calls
+func (m *Mock) Snapshot() *MockState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := &MockState{
+		expectedCalls: append([]*mock.Call{}, m.ExpectedCalls...),
+		states:        make([]mock.Call, 0, len(m.ExpectedCalls)),
+		calls:         append([]mock.Call{}, m.Calls...),
+	}
+
+	for _, c := range m.ExpectedCalls {
+		s.states = append(s.states, *c)
+	}
+
+	return s
+}
+
+// Restore resets the mock to the snapshot, discarding the expectations and calls added since; use it between
+// steps so each step's AssertExpectations is scoped to the expectations set before the snapshot and its own operations.
+// The snapshot expectations keep their pointers and get their call counts back. Restore must not run while a Do
+// on the mock is active, the mock.Mock lock is not exported and the calls it guards are replaced
+func (m *Mock) Restore(s *MockState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, c := range s.expectedCalls {
+		*c = s.states[i]
+	}
+
+	m.ExpectedCalls = append([]*mock.Call{}, s.expectedCalls...)
+	m.Calls = append([]mock.Call{}, s.calls...)
+}
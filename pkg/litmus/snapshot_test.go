@@ -0,0 +1,93 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMockSnapshot(t *testing.T) {
+	store := &userStore{}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		store.Audit(r.Method)
+
+		if r.Method == http.MethodPut {
+			store.Save(&user{ID: "u1"})
+		} else {
+			store.Get("u1")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// the audit expectations are shared by all steps
+	put := store.On("Audit", "PUT").Return().Maybe()
+	store.On("Audit", "GET").Return().Maybe()
+
+	base := store.Snapshot()
+
+	save := &Test{
+		Operations:         []Operation{{Name: "Save", Args: Args{&user{}}, Returns: Returns{nil}}},
+		Method:             http.MethodPut,
+		Path:               "/users/u1",
+		ExpectedStatus:     http.StatusNoContent,
+		ExpectedTotalCalls: 2,
+	}
+
+	get := &Test{
+		Operations:         []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}}},
+		Method:             http.MethodGet,
+		Path:               "/users/u1",
+		ExpectedStatus:     http.StatusNoContent,
+		ExpectedTotalCalls: 2,
+	}
+
+	// the steps share the store and run in order
+	steps := []struct {
+		name    string
+		test    *Test
+		restore bool
+		failure string
+	}{
+		{name: "save", test: save},
+		{name: "restored", test: get, restore: true},
+		{name: "restored save", test: save, restore: true},
+		{name: "not restored", test: get, failure: "backend called 4 times, expected 2"},
+	}
+
+	for _, s := range steps {
+		s := s
+
+		t.Run(s.name, func(t *testing.T) {
+			if s.restore {
+				// a step changing a snapshot expectation
+				put.Times(3)
+
+				store.Restore(base)
+
+				if len(store.ExpectedCalls) != 2 || len(store.Calls) != 0 {
+					t.Fatalf("mock not restored, %d expected calls and %d calls", len(store.ExpectedCalls), len(store.Calls))
+				}
+
+				if store.ExpectedCalls[0] != put || put.Repeatability != 0 {
+					t.Fatalf("expectation not restored in place, repeatability %d", put.Repeatability)
+				}
+			}
+
+			if s.failure != "" {
+				requireFailure(t, run(s.test, &store.Mock, handler), s.failure)
+				return
+			}
+
+			s.test.Do(&store.Mock, handler, t)
+		})
+	}
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+)
+
+type (
+	// Suite is a set of tests run against the same handler
+	Suite struct {
+		// Defaults are applied to a copy of each test that does not override them or set NoDefaults
+		Defaults Defaults
+
+		// Tests are the suite tests
+		Tests []*Test
+
+		spec *openAPISpec
+	}
+
+	// Result is the outcome of a suite test
+	Result struct {
+		// Name is the test name
+		Name string
+
+		// Passed is true if the test passed
+		Passed bool
+
+		// Failures are the reported failure messages
+		Failures []string
+	}
+
+	// recorder records failures reported to the wrapped test
+	recorder struct {
+		*testing.T
+
+		failures []string
+	}
+
+	// Defaults are suite level test defaults
+	Defaults struct {
+		// RequestContentType is the default request content type
+		RequestContentType string
+
+		// ExpectedContentType is the default expected content-type
+		ExpectedContentType string
+
+		// BasePath is the default base path prepended to the request path
+		BasePath string
+	}
+)
+
+// Run executes each suite test as a subtest, the backend expectations are reset between tests so the backend
+// must be a Mock, a mock.Mock or embed Mock
+func (s *Suite) Run(backend Backend, handler http.Handler, tt *testing.T) []Result {
+	results := make([]Result, 0, len(s.Tests))
+
+	for i, test := range s.Tests {
+		// the defaults are applied to a copy so the suite tests are left untouched
+		t := *test
+		t.Operations = append([]Operation{}, test.Operations...)
+
+		if !t.NoDefaults {
+			s.Defaults.apply(&t)
+		}
+
+		if !resetBackend(backend) {
+			tt.Fatalf("suite backend %T cannot be reset between tests, embed Mock", backend)
+		}
+
+		rec := &recorder{}
+
+		passed := tt.Run(t.name(i), func(tt *testing.T) {
+			rec.T = tt
+
+			if err := t.Validate(); err != nil {
+				rec.Fatalf("invalid test: %s", err.Error())
+			}
+
+			if s.spec != nil {
+				if err := s.spec.validate(&t); err != nil {
+					rec.Fatalf("request does not match openapi spec: %s", err.Error())
+				}
+			}
+
+			t.do(backend, handler, rec)
+		})
+
+		results = append(results, Result{
+			Name:     t.name(i),
+			Passed:   passed,
+			Failures: rec.failures,
+		})
+	}
+
+	return results
+}
+
+// RunAll runs each test as a subtest of each named handler; the handlers are built without a litmus backend so
+// the tests must not have operations, use RunAllFactories to build each handler around its backend
+func RunAll(tt *testing.T, handlers map[string]http.Handler, tests ...*Test) {
+	for i, t := range tests {
+		if len(t.Operations) > 0 {
+			tt.Fatalf("test %s has operations, use RunAllFactories to build the handlers around the backend", t.name(i))
+		}
+	}
+
+	factories := make(map[string]func() (Backend, http.Handler), len(handlers))
+
+	for name, h := range handlers {
+		h := h
+		factories[name] = func() (Backend, http.Handler) {
+			return &Mock{}, h
+		}
+	}
+
+	RunAllFactories(tt, factories, tests...)
+}
+
+// RunAllFactories runs each test as a subtest of each named implementation, the factory is called for each test
+// to build the backend and the handler using it
+func RunAllFactories(tt *testing.T, factories map[string]func() (Backend, http.Handler), tests ...*Test) {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		factory := factories[name]
+
+		tt.Run(name, func(tt *testing.T) {
+			for i, test := range tests {
+				t := *test
+				t.Operations = append([]Operation{}, test.Operations...)
+
+				backend, handler := factory()
+
+				tt.Run(t.name(i), func(tt *testing.T) {
+					t.do(backend, handler, tt)
+				})
+			}
+		})
+	}
+}
+
+func (d Defaults) apply(t *Test) {
+	if t.RequestContentType == "" {
+		t.RequestContentType = d.RequestContentType
+	}
+
+	if t.ExpectedContentType == "" {
+		t.ExpectedContentType = d.ExpectedContentType
+	}
+
+	if t.BasePath == "" {
+		t.BasePath = d.BasePath
+	}
+}
+
+func (t *Test) name(i int) string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return fmt.Sprintf("%d %s %s", i, t.Method, t.Path)
+}
+
+// Errorf records the failure and reports it to the test
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.T.Helper()
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+	r.T.Errorf(format, args...)
+}
+
+// Fatalf records the failure and reports it to the test
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.T.Helper()
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+	r.T.Fatalf(format, args...)
+}
@@ -0,0 +1,366 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSuiteDefaults(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if r.URL.Path == "/v1/export" {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("id,name"))
+			return
+		}
+
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"id":"1"}`))
+	})
+
+	suite := &Suite{
+		Defaults: Defaults{
+			RequestContentType:  "application/json",
+			ExpectedContentType: "application/json; charset=utf-8",
+			BasePath:            "/v1",
+		},
+		Tests: []*Test{
+			{
+				Name:             "defaults",
+				Method:           http.MethodPost,
+				Path:             "/users",
+				Request:          map[string]string{"name": "a"},
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: map[string]string{"id": "1"},
+			},
+			{
+				Name:                "override",
+				Method:              http.MethodGet,
+				Path:                "/export",
+				ExpectedStatus:      http.StatusOK,
+				ExpectedContentType: "text/plain",
+			},
+			{
+				Name:           "no defaults",
+				Method:         http.MethodGet,
+				Path:           "/health",
+				NoDefaults:     true,
+				ExpectedStatus: http.StatusNoContent,
+			},
+		},
+	}
+
+	for _, r := range suite.Run(&Mock{}, handler, t) {
+		if !r.Passed {
+			t.Errorf("test %s failed: %q", r.Name, r.Failures)
+		}
+	}
+
+	if ct := suite.Tests[1].ExpectedContentType; ct != "text/plain" {
+		t.Errorf("override replaced by default: %s", ct)
+	}
+
+	if d := suite.Tests[0]; d.ExpectedContentType != "" || d.RequestContentType != "" || d.BasePath != "" {
+		t.Errorf("suite test changed by defaults: %+v", d)
+	}
+}
+
+func TestSuiteResults(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	suite := &Suite{
+		Tests: []*Test{
+			{Name: "passes", Method: http.MethodGet, Path: "/", ExpectedStatus: http.StatusNoContent},
+			{Name: "fails", Method: http.MethodGet, Path: "/", ExpectedStatus: http.StatusOK},
+		},
+	}
+
+	if inSubprocess(t) {
+		printResults(suite.Run(&Mock{}, handler, t))
+		return
+	}
+
+	results := subprocessResults(t)
+
+	if len(results) != 2 {
+		t.Fatalf("%d results, expected 2", len(results))
+	}
+
+	if r := results[0]; r.Name != "passes" || !r.Passed || len(r.Failures) != 0 {
+		t.Errorf("unexpected result %+v", r)
+	}
+
+	if r := results[1]; r.Name != "fails" || r.Passed || len(r.Failures) != 1 || !strings.Contains(r.Failures[0], "actual  : 204") {
+		t.Errorf("unexpected result %+v", r)
+	}
+}
+
+func TestSuiteOpenAPI(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	cases := []struct {
+		test     *Test
+		failures []string
+	}{
+		{
+			test: &Test{Name: "valid", Method: http.MethodPut, Path: "/users/u1", Request: map[string]string{"ID": "u1", "Name": "Ann"}, ExpectedStatus: http.StatusNoContent},
+		},
+		{
+			test:     &Test{Name: "missing name", Method: http.MethodPut, Path: "/users/u1", Request: map[string]string{"ID": "u1"}, ExpectedStatus: http.StatusNoContent},
+			failures: []string{"request does not match openapi spec: PUT /users/u1: ", "required field Name missing"},
+		},
+		{
+			test: &Test{Name: "base path", Method: http.MethodPut, BasePath: "/v1", Path: "/users/u1", Request: map[string]string{"ID": "u1", "Name": "Ann"}, ExpectedStatus: http.StatusNoContent},
+		},
+		{
+			test: &Test{Name: "server path", Method: http.MethodPut, Path: "/api/users/u1", Request: map[string]string{"ID": "u1", "Name": "Ann"}, ExpectedStatus: http.StatusNoContent},
+		},
+		{
+			test:     &Test{Name: "unknown path", Method: http.MethodPut, BasePath: "/v1", Path: "/groups/g1", Request: map[string]string{"ID": "g1"}, ExpectedStatus: http.StatusNoContent},
+			failures: []string{"request does not match openapi spec: path /v1/groups/g1 not found"},
+		},
+		{
+			test:     &Test{Name: "invalid", Method: http.MethodGet, Path: "/users/u1", Request: map[string]string{"ID": "u1"}, ExpectedStatus: http.StatusNoContent},
+			failures: []string{"invalid test: request body set for GET without AllowRequestBody"},
+		},
+	}
+
+	suite := &Suite{}
+	for _, c := range cases {
+		suite.Tests = append(suite.Tests, c.test)
+	}
+
+	if err := suite.OpenAPI("testdata/users.openapi.yaml"); err != nil {
+		t.Fatalf("failed to load openapi spec: %s", err.Error())
+	}
+
+	if inSubprocess(t) {
+		printResults(suite.Run(&Mock{}, handler, t))
+		return
+	}
+
+	results := subprocessResults(t)
+
+	if len(results) != len(cases) {
+		t.Fatalf("%d results, expected %d", len(results), len(cases))
+	}
+
+	for i, c := range cases {
+		r := results[i]
+
+		if len(c.failures) == 0 && !r.Passed {
+			t.Errorf("%s request rejected: %q", r.Name, r.Failures)
+		}
+
+		if len(c.failures) > 0 && len(r.Failures) != 1 {
+			t.Errorf("%s reported %q, expected a single failure", r.Name, r.Failures)
+		}
+
+		for _, f := range c.failures {
+			requireFailure(t, r.Failures, f)
+		}
+	}
+}
+
+// printResults writes the suite results to the output of the subprocess
+func printResults(results []Result) {
+	data, _ := json.Marshal(results)
+	fmt.Printf("results: %s\n", data)
+}
+
+// subprocessResults runs the test in a subprocess and returns the suite results it printed
+func subprocessResults(tt *testing.T) []Result {
+	tt.Helper()
+
+	out := subprocess(tt)
+
+	i := strings.Index(out, "results: ")
+	if i < 0 {
+		tt.Fatalf("no results in output:\n%s", out)
+	}
+
+	var results []Result
+	if err := json.NewDecoder(strings.NewReader(out[i+len("results: "):])).Decode(&results); err != nil {
+		tt.Fatalf("failed to decode results: %s", err.Error())
+	}
+
+	return results
+}
+
+func TestRunAll(t *testing.T) {
+	v1 := jsonHandler(http.StatusOK, map[string]string{"status": "ok"})
+	v2 := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{ "status": "ok" }`))
+	})
+	broken := jsonHandler(http.StatusOK, map[string]string{"status": "degraded"})
+
+	health := &Test{
+		Name:             "health",
+		Method:           http.MethodGet,
+		Path:             "/health",
+		ExpectedStatus:   http.StatusOK,
+		ExpectedResponse: map[string]string{"status": "ok"},
+	}
+
+	if inSubprocess(t) {
+		RunAll(t, map[string]http.Handler{"v1": v1, "broken": broken}, health)
+		return
+	}
+
+	RunAll(t, map[string]http.Handler{"v1": v1, "v2": v2}, health)
+
+	out := subprocess(t)
+
+	if !strings.Contains(out, "--- PASS: TestRunAll/v1/health") || !strings.Contains(out, "--- FAIL: TestRunAll/broken/health") {
+		t.Errorf("unexpected subtest results:\n%s", out)
+	}
+}
+
+func TestRunAllFactories(t *testing.T) {
+	// the cached handler only calls the backend once, so the second test passes only with a fresh handler and backend
+	cached := func(store *userStore) http.Handler {
+		var cache *user
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cache == nil {
+				cache, _ = store.Get("u1")
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(cache)
+		})
+	}
+
+	direct := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, _ := store.Get("u1")
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(u)
+		})
+	}
+
+	factory := func(handler func(store *userStore) http.Handler) func() (Backend, http.Handler) {
+		return func() (Backend, http.Handler) {
+			store := &userStore{}
+			return store, handler(store)
+		}
+	}
+
+	get := &Test{
+		Name:             "get",
+		Operations:       []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}}},
+		Method:           http.MethodGet,
+		Path:             "/users/u1",
+		ExpectedStatus:   http.StatusOK,
+		ExpectedResponse: OperationReturn(0),
+	}
+
+	RunAllFactories(t, map[string]func() (Backend, http.Handler){
+		"cached": factory(cached),
+		"direct": factory(direct),
+	}, get, get)
+}
+
+func TestSuiteBackend(t *testing.T) {
+	handler := func(get func(id string) (*user, error)) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, _ := get("u1")
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(u)
+		})
+	}
+
+	cases := []struct {
+		name    string
+		backend func() (Backend, http.Handler)
+		failure string
+	}{
+		{
+			name: "mock",
+			backend: func() (Backend, http.Handler) {
+				store := &userStore{}
+				return &store.Mock, handler(store.Get)
+			},
+		},
+		{
+			name: "embedded mock",
+			backend: func() (Backend, http.Handler) {
+				store := &userStore{}
+				return store, handler(store.Get)
+			},
+		},
+		{
+			name: "testify mock",
+			backend: func() (Backend, http.Handler) {
+				store := &wrappedStore{}
+				return &store.Mock, handler(store.Get)
+			},
+		},
+		{
+			name: "not resettable",
+			backend: func() (Backend, http.Handler) {
+				store := &wrappedStore{}
+				return store, handler(store.Get)
+			},
+			failure: "suite backend *litmus.wrappedStore cannot be reset between tests, embed Mock",
+		},
+	}
+
+	get := &Test{
+		Operations:       []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}, Times: 1}},
+		Method:           http.MethodGet,
+		Path:             "/users/u1",
+		ExpectedStatus:   http.StatusOK,
+		ExpectedResponse: OperationReturn(0),
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			// the failing suites run in a child process
+			if c.failure != "" && !inSubprocess(t) {
+				if out := subprocess(t); !strings.Contains(out, c.failure) {
+					t.Errorf("expected %q to be reported:\n%s", c.failure, out)
+				}
+				return
+			}
+
+			backend, h := c.backend()
+
+			// the second run passes only if the Times expectation is reset
+			for _, r := range (&Suite{Tests: []*Test{get, get}}).Run(backend, h, t) {
+				if !r.Passed {
+					t.Errorf("test %s failed: %q", r.Name, r.Failures)
+				}
+			}
+		})
+	}
+}
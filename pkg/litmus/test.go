@@ -10,21 +10,30 @@ package litmus
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"reflect"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
-	"github.com/tj/assert"
 )
 
 type (
@@ -33,6 +42,26 @@ type (
 		mock.Mock
 
 		t *Test
+
+		mu       sync.Mutex
+		defaults map[string]*mock.Call
+	}
+
+	// Backend is a testify compatible mock the test operations are registered on, such as *Mock, a type embedding
+	// Mock or a type embedding mock.Mock; return stacks, return maps, DefaultReturns and FlushAfter timing require
+	// the backend to be or embed a Mock
+	Backend interface {
+		On(methodName string, arguments ...interface{}) *mock.Call
+		MethodCalled(methodName string, arguments ...interface{}) mock.Arguments
+		AssertExpectations(t mock.TestingT) bool
+	}
+
+	// boundBackend is implemented by *Mock and the types embedding it
+	boundBackend interface {
+		bind(t *Test)
+		recordedCalls() []mock.Call
+		reset()
+		resetDefaults()
 	}
 
 	// Operation is a backend operation
@@ -49,9 +78,34 @@ type (
 		// ReturnStack handles a return stack for multiple calls
 		ReturnStack [][]interface{}
 
+		// ReturnMap are the returns for calls by ArgKey of the call args, args matched by a matcher in Args are
+		// skipped, i.e. Args{litmus.Context, "a"} is keyed by ArgKey("a"); calls with other args use Returns
+		ReturnMap map[string][]interface{}
+
 		// Optional backend for this operation
 		Backend *mock.Mock
 
+		// Optional allows the operation to not be called
+		Optional bool
+
+		// Times is the exact number of calls expected, zero is unbounded
+		Times int
+
+		// MinTimes is the minimum number of calls expected, zero is unbounded
+		MinTimes int
+
+		// MaxTimes is the maximum number of calls expected, zero is unbounded
+		MaxTimes int
+
+		// Delay blocks the operation for the duration before returning
+		Delay time.Duration
+
+		// WaitFor blocks the operation until the channel receives or is closed, it is invalid with Delay
+		WaitFor <-chan time.Time
+
+		// Panic makes the operation panic with the value instead of returning
+		Panic interface{}
+
 		call *mock.Call
 	}
 
@@ -65,51 +119,336 @@ type (
 
 		// Return refers to the Returns index
 		Return int
+
+		// Element is an optional index into a referenced slice or array
+		Element *int
+
+		// Field is an optional dotted path to an exported field of the referenced value
+		Field string
 	}
 
 	// Test is a test requirements object
 	Test struct {
+		// Name is the test name used when run as part of a suite
+		Name string
+
 		// Operations are the backend operations to prepare for test
 		Operations []Operation
 
-		// Method the http method
+		// DefaultReturns are the returns by method name of optional fallback operations matching any args,
+		// for incidental calls not covered by Operations; declared operations are never served from them and they
+		// are removed when the test ends; requires a backend embedding Mock
+		DefaultReturns map[string][]interface{}
+
+		// StrictBackend is the concrete backend, when set each operation name must be one of its methods
+		StrictBackend interface{}
+
+		// SkipExpectations skips asserting the backend expectations were met, for exploratory tests
+		SkipExpectations bool
+
+		// NoDefaults runs the test without the suite Defaults, its empty content types and base path are kept
+		NoDefaults bool
+
+		// ExpectedTotalCalls is the exact number of backend calls expected, zero is unbounded
+		ExpectedTotalCalls int
+
+		// Method the http method, it is sent unchanged so extension methods such as PROPFIND are supported
 		Method string
 
+		// BasePath is prepended to the request path
+		BasePath string
+
 		// The request path
 		Path string
 
 		// Query is the request query parameters
 		Query url.Values
 
+		// ExpectedParsedQuery is the query the server is expected to parse from the request
+		ExpectedParsedQuery url.Values
+
 		// Request is the http request body put on the wire
 		// []byte or string will be posted directly
+		// chan []byte will be streamed until the channel is closed
 		// if Request is *OperationRef that value will be used
 		// everything else will be marshalled to json
 		Request interface{}
 
+		// RequestObject is a prebuilt request retargeted at the test server, it replaces
+		// the request built from Method, Path, Query, Request and RequestContentType
+		RequestObject *http.Request
+
 		// RequestContentType is the request content type, default application/json
 		RequestContentType string
 
+		// ChunkedRequest sends the request body with chunked transfer encoding and no Content-Length
+		ChunkedRequest bool
+
+		// RequestTrailers are sent as request trailers after the body, the body is sent chunked
+		RequestTrailers map[string]string
+
+		// NoRequestBody asserts no request body is set or received by the handler
+		NoRequestBody bool
+
+		// AllowRequestBody permits a Request with GET and HEAD, which are rejected by Validate otherwise
+		AllowRequestBody bool
+
+		// ExpectedRequestContentLength is the Content-Length the handler receives, zero is unchecked and -1 is unknown
+		ExpectedRequestContentLength int64
+
+		// RetryOn are response statuses that cause the request to be retried, operations are shared by
+		// all attempts so the exact Times is invalid with MaxRetries, MinTimes and MaxTimes count every attempt
+		RetryOn []int
+
+		// MaxRetries is the maximum number of retries for RetryOn statuses
+		MaxRetries int
+
+		// RetryBackoff is the delay between retries
+		RetryBackoff time.Duration
+
 		// ExpectedStatus is the expected http status
 		ExpectedStatus int
 
+		// HandlerFactory builds the handler with the test server url, replacing the handler passed to Do
+		HandlerFactory func(baseURL string) http.Handler
+
+		// ReferenceHandler is also sent the request and its status, headers and body must match the handler,
+		// the backend is shared so operations are called by both handlers
+		ReferenceHandler http.Handler
+
+		// FuzzStatuses are the acceptable statuses for fuzzed requests, any status below 500 if unset
+		FuzzStatuses []int
+
 		// ExpectedHeaders are expected response headers
 		ExpectedHeaders map[string]string
 
+		// ExpectedHeaderValues are the exact values of repeated response headers, one per header line
+		ExpectedHeaderValues map[string][]string
+
+		// UnorderedHeaderValues compares ExpectedHeaderValues ignoring order
+		UnorderedHeaderValues bool
+
+		// ExpectChunked asserts the response is sent with chunked transfer encoding and no Content-Length
+		ExpectChunked bool
+
+		// ExpectedRetryAfter is the range the Retry-After header delay, in seconds or an http date, must be within
+		ExpectedRetryAfter *DurationRange
+
+		// ExpectedDisposition is the expected Content-Disposition type and filename
+		ExpectedDisposition *Disposition
+
+		// ExpectedLocationQuery are query parameters the Location header url must have, other parameters are ignored
+		ExpectedLocationQuery url.Values
+
+		// RequiredHeaders are response headers that must be present with any non-empty value
+		RequiredHeaders []string
+
+		// ForbiddenHeaders are response headers that must not be present
+		ForbiddenHeaders []string
+
 		// ExpectedContentType is the expected content-type
 		ExpectedContentType string
 
+		// ExpectedContentTypes are acceptable content-types for negotiated responses
+		ExpectedContentTypes []string
+
+		// StrictNumbers compares json numbers by their literal representation, preserving precision
+		StrictNumbers bool
+
+		// UnorderedArrays compares all json arrays as multisets
+		UnorderedArrays bool
+
+		// UnorderedPaths are dotted paths of json arrays compared as multisets, "" is the root
+		// value and paths continue through array elements, i.e. "items.tags"
+		UnorderedPaths []string
+
+		// IgnoreArrayFields are object fields removed from the elements of every json array before comparison
+		IgnoreArrayFields []string
+
+		// StrictKeyOrder asserts the json object keys are in the expected order
+		StrictKeyOrder bool
+
+		// StringNumberPaths are dotted paths of json strings compared as numbers if numeric, i.e. "items.amount"
+		StringNumberPaths []string
+
+		// FloatTolerance is the maximum difference of json numbers compared as equal
+		FloatTolerance float64
+
+		// UnorderedRows compares csv records as a multiset
+		UnorderedRows bool
+
+		// IgnoreCSVHeader skips the first csv record of the expected and actual responses
+		IgnoreCSVHeader bool
+
+		// Golden is the path of a file the response body is compared to, rewritten when Update is set, i.e. by
+		// running the tests with LITMUS_UPDATE=1 rather than an -update flag
+		Golden string
+
+		// ExpectEmptyBody asserts the response body is zero-length, as does an empty string ExpectedResponse
+		ExpectEmptyBody bool
+
+		// ExpectedStruct is unmarshalled from the response into a new value of the same type and compared
+		ExpectedStruct interface{}
+
+		// DisallowUnknownFields is a value the response is decoded into a new value of the type of, failing on
+		// fields with no matching struct field and on type mismatches
+		DisallowUnknownFields interface{}
+
+		// ExpectedContainsValue are json values that must each be found at any depth of the response
+		ExpectedContainsValue []interface{}
+
+		// ExpectedPage asserts the response is a pagination envelope
+		ExpectedPage *Page
+
+		// ExpectedProblem is a RFC 7807 problem compared with the response, unset fields and extensions are ignored
+		ExpectedProblem *Problem
+
+		// RequireValidJSON asserts json responses are well-formed regardless of ExpectedResponse
+		RequireValidJSON bool
+
+		// AssertChanged are dotted paths that must differ between the json request and response bodies
+		AssertChanged []string
+
+		// AssertUnchanged are dotted paths that must be equal in the json request and response bodies
+		AssertUnchanged []string
+
+		// PerfBaseline is the path of a file holding the baseline request duration, rewritten when Update is set
+		PerfBaseline string
+
+		// PerfTolerance is the allowed fractional slowdown over the baseline, 0.1 allows 10%
+		PerfTolerance float64
+
+		// MaxResponseBytes is the maximum response body size read, including chunked responses
+		MaxResponseBytes int
+
 		// ExpectedResponse is expected wire response
 		// []byte or string will be posted directly
+		// []byte is compared byte for byte with non-textual content types
+		// *regexp.Regexp will be matched against the raw body
+		// AnyOf passes if the body matches any of the candidates
+		// func() interface{} is called after the request and its result marshalled
+		// text/csv ExpectedContentType compares raw or [][]string records, see UnorderedRows
+		// application/x-www-form-urlencoded ExpectedContentType compares the encoded form, url.Values or map[string]string by value
 		// if Request is *OperationRef that value will be used
 		// everything else will be marshalled to json
+		// nil skips the body comparison, status and headers are still asserted
 		ExpectedResponse interface{}
 
-		// Redirect overrides the http client redirect
+		// ExpectedResponses are the expected responses by status, the response status must be one of the keys
+		// and the body is compared as the ExpectedResponse for it; ExpectedStatus is not asserted
+		ExpectedResponses map[int]interface{}
+
+		// Env are environment variables set for the duration of the test and restored after,
+		// since the environment is process global tests using Env must not run in parallel
+		Env map[string]string
+
+		// Host overrides the request Host header independent of the server address
+		Host string
+
+		// ServerName is the TLS server name (SNI) sent by the client, certificate verification is skipped
+		ServerName string
+
+		// CancelAfter cancels the request context after the duration, simulating a client disconnect;
+		// if the request is canceled the test waits for the handler to return and skips the response assertions;
+		// it is invalid with DoAgainst, the handler of an existing server cannot be waited for
+		CancelAfter time.Duration
+
+		// OnTLS is called with the negotiated connection state of the response
+		OnTLS func(state *tls.ConnectionState)
+
+		// ExpectedTLSVersion is the minimum negotiated TLS version, i.e. tls.VersionTLS12
+		ExpectedTLSVersion uint16
+
+		// NetworkConditions adds latency, failures and a timeout to the client
+		NetworkConditions *NetworkConditions
+
+		// ExpectedClientError is the error the request is expected to fail with, matched with errors.Is, i.e.
+		// ErrNetworkFailure or ErrClientTimeout; the response assertions are skipped
+		ExpectedClientError error
+
+		// HTTP10 sends the request as HTTP/1.0 without keep-alive on a new connection
+		HTTP10 bool
+
+		// Redirect is called before each redirect is followed, setting it follows redirects
 		Redirect func(req *http.Request, via []*http.Request)
 
+		// FollowRedirects follows up to 10 redirects, 307 and 308 resend the method and body
+		// while 301, 302 and 303 change the method to GET without a body
+		FollowRedirects bool
+
+		// ExpectedFinalMethod is the method of the last request sent when following redirects
+		ExpectedFinalMethod string
+
+		// FinalRequest is set to the last request sent when following redirects
+		FinalRequest *http.Request
+
 		// Setup is call before the request is executed
 		Setup func(r *http.Request)
+
+		// SetupTest is called after Setup with access to the test, i.e. to read operation args
+		SetupTest func(r *http.Request, t *Test)
+
+		// SignRequest is called with the final request body and returns a signature header
+		SignRequest func(body []byte) (headerName, headerValue string)
+
+		// ValidateResponse is called with the response and body after the request is executed
+		ValidateResponse func(t *Test, resp *http.Response, body []byte) error
+
+		// ExpectPanic asserts the handler panics, the panic is recovered as a 500 response
+		ExpectPanic bool
+
+		// OnPanic is called with the recovered handler panic value
+		OnPanic func(v interface{})
+
+		// OutboundCapture is reset before the request, the handler must send its outbound requests with it
+		OutboundCapture *OutboundCapture
+
+		// ExpectedOutboundHeaders are headers expected on each request captured by OutboundCapture
+		ExpectedOutboundHeaders map[string]string
+
+		// ExpectedMethod is the method expected to reach the handler wrapped with Observe
+		ExpectedMethod string
+
+		// ObservedMethod is set to the method that reached the handler wrapped with Observe
+		ObservedMethod string
+
+		// LogOutput is the buffer the handler under test logs to, it must be wired by the caller
+		LogOutput *bytes.Buffer
+
+		// ExpectedLog is a regular expression the log output must match
+		ExpectedLog string
+
+		// State is shared between the test hooks, Setup can access it via RequestState
+		State map[string]interface{}
+
+		// ContextValues are set on the server request context before the handler, simulating upstream middleware
+		ContextValues map[interface{}]interface{}
+
+		// FlushAfter are operations that must complete before the handler first flushes the response,
+		// or returns if it never flushes
+		FlushAfter []string
+
+		timing *timing
+
+		// server is the existing server the test is run against
+		server *httptest.Server
+
+		// replay skips registering the operations that are already set on the backend
+		replay bool
+
+		// roundTrip replays the request conditionally, see ConditionalRoundTrip
+		roundTrip bool
+	}
+
+	stateKey struct{}
+
+	// testingT is the subset of *testing.T used to report test failures
+	testingT interface {
+		Errorf(format string, args ...interface{})
+		Fatalf(format string, args ...interface{})
+		FailNow()
+		Helper()
+		Logf(format string, args ...interface{})
 	}
 
 	// RequestHandler can be used to generate a request body dynamically
@@ -157,178 +496,1180 @@ var (
 )
 
 // Do executes the test
-func (t *Test) Do(backend *Mock, handler http.Handler, tt *testing.T) {
+func (t *Test) Do(backend Backend, handler http.Handler, tt *testing.T) {
+	tt.Helper()
+
+	if t.roundTrip {
+		t.doRoundTrip(backend, handler, tt)
+		return
+	}
+
+	t.do(backend, handler, tt)
+}
+
+// DoAgainst executes the test against the existing server and its client, the fields that observe the request
+// in the server, i.e. NoRequestBody, ExpectedParsedQuery, ExpectPanic, ContextValues, AssertChanged and CancelAfter,
+// are invalid
+func (t *Test) DoAgainst(server *httptest.Server, backend Backend, tt *testing.T) {
+	tt.Helper()
+
+	t.server = server
 	defer func() {
-		backend.AssertExpectations(tt)
+		t.server = nil
 	}()
 
-	backend.t = t
+	t.do(backend, nil, tt)
+}
 
-	for i, o := range t.Operations {
-		args := make([]interface{}, 0)
-		for _, a := range o.Args {
-			if any, ok := a.(mock.AnythingOfTypeArgument); ok {
-				args = append(args, any)
-			} else {
-				args = append(args, mock.AnythingOfType(reflect.TypeOf(a).String()))
+// DoFunc executes the test with a handler function
+func (t *Test) DoFunc(backend Backend, handler func(w http.ResponseWriter, r *http.Request), tt *testing.T) {
+	tt.Helper()
+
+	t.do(backend, http.HandlerFunc(handler), tt)
+}
+
+func (t *Test) do(backend Backend, handler http.Handler, tt testingT) {
+	tt.Helper()
+
+	defer func() {
+		tt.Helper()
+
+		t.assertCallCounts(backend, tt)
+
+		if !t.SkipExpectations {
+			backend.AssertExpectations(tt)
+		}
+
+		if b, ok := backend.(boundBackend); ok {
+			b.resetDefaults()
+		}
+	}()
+
+	if b, ok := backend.(boundBackend); ok {
+		b.bind(t)
+	} else if len(t.DefaultReturns) > 0 {
+		tt.Fatalf("DefaultReturns requires a backend embedding Mock, got %T", backend)
+	}
+
+	defer t.setEnv(tt)()
+
+	t.ObservedMethod = ""
+
+	if len(t.FlushAfter) > 0 {
+		t.timing = newTiming()
+	}
+
+	if t.OutboundCapture != nil {
+		t.OutboundCapture.Reset()
+	}
+
+	if err := t.Validate(); err != nil {
+		tt.Fatalf("invalid test: %s", err.Error())
+	}
+
+	if !t.replay {
+		t.registerOperations(backend)
+	}
+
+	var received struct {
+		contentLength    int64
+		transferEncoding []string
+		query            url.Values
+		panicked         bool
+		panicValue       interface{}
+		body             []byte
+	}
+
+	serve := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.contentLength = r.ContentLength
+		received.transferEncoding = r.TransferEncoding
+		received.query = r.URL.Query()
+
+		if len(t.AssertChanged) > 0 || len(t.AssertUnchanged) > 0 {
+			data, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
 			}
+			received.body = data
+			r.Body = ioutil.NopCloser(bytes.NewReader(data))
 		}
-		returns := o.Returns
-		if returns == nil && len(o.ReturnStack) > 0 {
-			returns = o.ReturnStack[len(o.ReturnStack)-1]
+
+		if t.ExpectPanic || t.OnPanic != nil {
+			defer func() {
+				if v := recover(); v != nil {
+					received.panicked = true
+					received.panicValue = v
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}()
 		}
-		if o.Backend != nil {
-			o.call = o.Backend.On(o.Name, args...).Return(returns...)
-		} else {
-			o.call = backend.On(o.Name, args...).Return(returns...)
+
+		if len(t.ContextValues) > 0 {
+			ctx := r.Context()
+			for k, v := range t.ContextValues {
+				ctx = context.WithValue(ctx, k, v)
+			}
+			r = r.WithContext(ctx)
 		}
 
-		t.Operations[i] = o
-	}
+		if t.timing != nil {
+			w = &flushWriter{ResponseWriter: w, timing: t.timing}
+			defer t.timing.flush()
+		}
 
-	ts := httptest.NewTLSServer(handler)
-	defer ts.Close()
+		handler.ServeHTTP(w, r)
+	})
 
-	client := ts.Client()
+	ts := t.server
+	if ts == nil {
+		ts = httptest.NewTLSServer(serve)
+		defer ts.Close()
 
-	if t.Redirect == nil {
-		client.CheckRedirect = NoRedirect
+		if t.HandlerFactory != nil {
+			handler = t.HandlerFactory(ts.URL)
+		}
 	}
 
-	var body io.Reader
+	c := *ts.Client()
+	client := &c
+	client.CheckRedirect = t.checkRedirect()
 
-	switch m := t.Request.(type) {
-	case []byte:
-		body = bytes.NewReader(m)
-	case string:
-		body = strings.NewReader(m)
-	case nil:
-		// do nothing
-	case *OperationRef:
-		data, err := json.Marshal(t.Operations[m.Index].Args[m.Arg])
-		if err != nil {
-			tt.Fatalf("failed to marshal request: %s", err.Error())
+	if t.ServerName != "" {
+		tr := client.Transport.(*http.Transport).Clone()
+		if tr.TLSClientConfig == nil {
+			tr.TLSClientConfig = &tls.Config{}
 		}
-		body = bytes.NewReader(data)
-	case RequestHandler:
-		b, err := m(backend, t)
-		if err != nil {
-			tt.Fatalf("failed to build request body: %s", err.Error())
+		tr.TLSClientConfig.ServerName = t.ServerName
+		tr.TLSClientConfig.InsecureSkipVerify = true
+		client.Transport = tr
+	}
+
+	if t.HTTP10 {
+		client.Transport = &http10Transport{
+			config: client.Transport.(*http.Transport).TLSClientConfig,
 		}
-		body = b
-	default:
-		data, err := json.Marshal(m)
-		if err != nil {
-			tt.Fatalf("failed to marshal request: %s", err.Error())
+	}
+
+	if t.NetworkConditions != nil {
+		client.Transport = &networkTransport{
+			next:       client.Transport,
+			conditions: t.NetworkConditions,
+			rand:       rand.New(rand.NewSource(t.NetworkConditions.Seed)),
 		}
-		body = bytes.NewReader(data)
+		client.Timeout = t.NetworkConditions.Timeout
 	}
 
-	req, err := http.NewRequest(t.Method, ts.URL+t.Path, body)
-	if err != nil {
-		tt.Fatalf("failed to create request: %s", err.Error())
+	var req *http.Request
+
+	if t.RequestObject != nil {
+		req = t.retargetRequest(ts.URL)
+	} else {
+		req = t.newRequest(backend, ts.URL, tt)
+	}
+
+	if t.Host != "" {
+		req.Host = t.Host
+	}
+
+	if len(t.RequestTrailers) > 0 {
+		if req.Body == nil {
+			req.Body = ioutil.NopCloser(strings.NewReader(""))
+		}
+
+		req.Trailer = make(http.Header)
+		for k, v := range t.RequestTrailers {
+			req.Trailer.Set(k, v)
+		}
+
+		req.ContentLength = -1
+	}
+
+	if t.ChunkedRequest && req.Body != nil {
+		req.ContentLength = -1
+		req.TransferEncoding = []string{"chunked"}
 	}
-	req.URL.RawQuery = t.Query.Encode()
 
-	if t.RequestContentType == "" {
-		t.RequestContentType = "application/json"
+	if t.State == nil {
+		t.State = make(map[string]interface{})
 	}
 
-	req.Header.Set("Content-Type", t.RequestContentType)
+	req = req.WithContext(context.WithValue(req.Context(), stateKey{}, t.State))
 
 	if t.Setup != nil {
 		t.Setup(req)
 	}
-	resp, err := client.Do(req)
-	if err != nil {
-		tt.Fatalf("failed to execute request: %s", err.Error())
+
+	if t.SetupTest != nil {
+		t.SetupTest(req, t)
 	}
 
-	assert.Equal(tt, t.ExpectedStatus, resp.StatusCode)
+	if t.CancelAfter > 0 {
+		ctx, cancel := context.WithCancel(req.Context())
+		defer cancel()
 
-	if t.ExpectedContentType != "" {
-		assert.Equal(tt, t.ExpectedContentType, resp.Header.Get("Content-Type"))
-	}
+		timer := time.AfterFunc(t.CancelAfter, cancel)
+		defer timer.Stop()
 
-	for k, v := range t.ExpectedHeaders {
-		assert.Regexp(tt, v, resp.Header.Get(k))
+		req = req.WithContext(ctx)
 	}
 
-	data, err := ioutil.ReadAll(resp.Body)
+	start := time.Now()
+
+	resp, err := client.Do(req)
 	if err != nil {
-		if err != io.EOF {
-			require.NoError(tt, err)
+		if t.CancelAfter > 0 && errors.Is(err, context.Canceled) {
+			// waits for the handler, CancelAfter is only valid with the test server
+			ts.Close()
+			return
 		}
+		if t.ExpectedClientError != nil {
+			t.assertClientError(tt, err)
+			return
+		}
+		tt.Fatalf("failed to execute request: %s", err.Error())
 	}
 
-	var expectedResp string
+	elapsed := time.Since(start)
 
-	switch m := t.ExpectedResponse.(type) {
-	case []byte:
-		expectedResp = string(m)
-	case string:
-		expectedResp = m
-	case nil:
-		return
-	case *OperationRef:
-		data, err := json.Marshal(t.Operations[m.Index].Returns[m.Return])
+	for i := 0; i < t.MaxRetries && t.retryable(resp.StatusCode); i++ {
+		resp.Body.Close()
+
+		time.Sleep(t.RetryBackoff)
+
+		req, err = replayRequest(req)
 		if err != nil {
-			tt.Fatalf("failed to marshal response: %s", err.Error())
+			tt.Fatalf("failed to retry request: %s", err.Error())
 		}
-		expectedResp = string(data)
-	default:
-		data, err := json.Marshal(m)
+
+		start = time.Now()
+
+		resp, err = client.Do(req)
 		if err != nil {
-			tt.Fatalf("failed to marshal response: %s", err.Error())
+			if t.ExpectedClientError != nil {
+				t.assertClientError(tt, err)
+				return
+			}
+			tt.Fatalf("failed to execute request: %s", err.Error())
 		}
-		expectedResp = string(data)
-	}
 
-	if len(data) > 0 {
-		assert.JSONEq(tt, expectedResp, string(data))
+		elapsed = time.Since(start)
 	}
-}
 
-// Called tells the mock object that a method has been called, and gets an array
-// of arguments to return.  Panics if the call is unexpected (i.e. not preceded by
-// appropriate .On .Return() calls)
-// If Call.WaitFor is set, blocks until the channel is closed or receives a message.
-func (m *Mock) Called(arguments ...interface{}) mock.Arguments {
-	// get the calling function's name
-	pc, _, _, ok := runtime.Caller(1)
-	if !ok {
-		panic("Couldn't get the caller information")
+	if t.ExpectedClientError != nil {
+		resp.Body.Close()
+		tt.Fatalf("request succeeded with status %d, expected client error %s", resp.StatusCode, t.ExpectedClientError)
 	}
-	functionPath := runtime.FuncForPC(pc).Name()
-	//Next four lines are required to use GCCGO function naming conventions.
-	//For Ex:  github_com_docker_libkv_store_mock.WatchTree.pN39_github_com_docker_libkv_store_mock.Mock
-	//uses interface information unlike golang github.com/docker/libkv/store/mock.(*Mock).WatchTree
-	//With GCCGO we need to remove interface information starting from pN<dd>.
-	re := regexp.MustCompile("\\.pN\\d+_")
-	if re.MatchString(functionPath) {
-		functionPath = re.Split(functionPath, -1)[0]
+
+	if t.PerfBaseline != "" {
+		t.assertPerf(tt, elapsed)
 	}
-	parts := strings.Split(functionPath, ".")
-	functionName := parts[len(parts)-1]
-	return m.MethodCalled(functionName, arguments...)
-}
 
-// MethodCalled wraps mock.MethodCalled to handle return stacks
-func (m *Mock) MethodCalled(methodName string, arguments ...interface{}) mock.Arguments {
-	for i, op := range m.t.Operations {
-		if op.Name == methodName {
-			if len(op.ReturnStack) > 0 {
-				n := len(op.ReturnStack) - 1
-				op.call.ReturnArguments = mock.Arguments(op.ReturnStack[0])
-				op.ReturnStack = op.ReturnStack[n:]
+	t.FinalRequest = resp.Request
+
+	if t.ExpectedFinalMethod != "" {
+		require.Equal(tt, t.ExpectedFinalMethod, resp.Request.Method, "unexpected final request method")
+	}
+
+	if t.ExpectedTLSVersion > 0 {
+		if resp.TLS == nil {
+			tt.Fatalf("response not received over tls")
+		}
+		if resp.TLS.Version < t.ExpectedTLSVersion {
+			tt.Errorf("negotiated tls version %#04x, expected at least %#04x", resp.TLS.Version, t.ExpectedTLSVersion)
+		}
+	}
+
+	if t.OnTLS != nil {
+		t.OnTLS(resp.TLS)
+	}
+
+	if t.ExpectPanic && !received.panicked {
+		tt.Errorf("expected handler panic")
+	}
+
+	if received.panicked && t.OnPanic != nil {
+		t.OnPanic(received.panicValue)
+	}
+
+	if t.ExpectedParsedQuery != nil {
+		require.Equal(tt, t.ExpectedParsedQuery, received.query, "unexpected query parsed by server")
+	}
+
+	if t.ExpectedMethod != "" {
+		require.Equal(tt, t.ExpectedMethod, t.ObservedMethod, "unexpected method observed by handler")
+	}
+
+	if t.NoRequestBody {
+		require.Equal(tt, int64(0), received.contentLength, "unexpected request body")
+		require.Empty(tt, received.transferEncoding, "unexpected request transfer encoding")
+	}
+
+	if t.ExpectedRequestContentLength != 0 {
+		require.Equal(tt, t.ExpectedRequestContentLength, received.contentLength, "unexpected request content length")
+	}
+
+	// the expected response of the returned status, the test is left untouched
+	expectedResponse := t.ExpectedResponse
+
+	if len(t.ExpectedResponses) > 0 {
+		expected, ok := t.ExpectedResponses[resp.StatusCode]
+		if !ok {
+			tt.Fatalf("unexpected status %d, expected one of %v", resp.StatusCode, t.responseStatuses())
+		}
+
+		expectedResponse = expected
+	} else {
+		require.Equal(tt, t.ExpectedStatus, resp.StatusCode)
+	}
+
+	if t.ExpectedContentType != "" {
+		require.Equal(tt, t.ExpectedContentType, resp.Header.Get("Content-Type"))
+	}
+
+	if len(t.ExpectedContentTypes) > 0 {
+		require.Contains(tt, t.ExpectedContentTypes, resp.Header.Get("Content-Type"))
+	}
+
+	for k, v := range t.ExpectedHeaders {
+		require.Regexp(tt, v, resp.Header.Get(k))
+	}
+
+	if t.ExpectChunked {
+		require.Equal(tt, []string{"chunked"}, resp.TransferEncoding, "unexpected response transfer encoding")
+		require.Equal(tt, int64(-1), resp.ContentLength, "unexpected response content length")
+	}
+
+	if t.ExpectedDisposition != nil {
+		t.assertDisposition(tt, resp.Header)
+	}
+
+	if t.ExpectedLocationQuery != nil {
+		loc, err := url.Parse(resp.Header.Get("Location"))
+		if err != nil {
+			tt.Fatalf("failed to parse location: %s", err.Error())
+		}
+
+		q := loc.Query()
+		for k, v := range t.ExpectedLocationQuery {
+			require.Equal(tt, v, q[k], "unexpected location query parameter %s", k)
+		}
+	}
+
+	for _, k := range t.RequiredHeaders {
+		if resp.Header.Get(k) == "" {
+			tt.Errorf("required response header %s missing", k)
+		}
+	}
+
+	for k, v := range t.ExpectedHeaderValues {
+		if t.UnorderedHeaderValues {
+			require.ElementsMatch(tt, v, resp.Header.Values(k), "header %s", k)
+		} else {
+			require.Equal(tt, v, resp.Header.Values(k), "header %s", k)
+		}
+	}
+
+	if t.ExpectedRetryAfter != nil {
+		t.assertRetryAfter(tt, resp.Header)
+	}
+
+	for _, k := range t.ForbiddenHeaders {
+		if v := resp.Header.Values(k); len(v) > 0 {
+			tt.Fatalf("forbidden response header %s present: %q", k, strings.Join(v, ", "))
+		}
+	}
+
+	var respBody io.Reader = resp.Body
+	if t.MaxResponseBytes > 0 {
+		respBody = io.LimitReader(resp.Body, int64(t.MaxResponseBytes)+1)
+	}
+
+	data, err := ioutil.ReadAll(respBody)
+	if err != nil {
+		if err != io.EOF {
+			require.NoError(tt, err)
+		}
+	}
+	resp.Body.Close()
+
+	if t.MaxResponseBytes > 0 && len(data) > t.MaxResponseBytes {
+		tt.Fatalf("response body exceeded %d bytes", t.MaxResponseBytes)
+	}
+
+	if len(t.FlushAfter) > 0 {
+		t.assertFlushOrder(tt)
+	}
+
+	if t.OutboundCapture != nil {
+		t.assertOutbound(tt)
+	}
+
+	if t.ExpectedLog != "" {
+		require.Regexp(tt, t.ExpectedLog, t.LogOutput.String())
+	}
+
+	if t.ValidateResponse != nil {
+		if err := t.ValidateResponse(t, resp, data); err != nil {
+			tt.Fatalf("failed to validate response: %s", err.Error())
+		}
+	}
+
+	if t.RequireValidJSON && isJSONContentType(resp.Header.Get("Content-Type")) {
+		if err := validJSON(data); err != nil {
+			tt.Fatalf("invalid json response: %s", err.Error())
+		}
+	}
+
+	if t.ReferenceHandler != nil {
+		t.assertReference(tt, req, resp, data)
+	}
+
+	// HEAD responses never carry a body to compare
+	if t.Method == http.MethodHead {
+		return
+	}
+
+	if t.ExpectEmptyBody {
+		require.Empty(tt, string(data), "unexpected response body")
+	}
+
+	if t.Golden != "" {
+		t.golden(tt, resp.Header.Get("Content-Type"), data)
+	}
+
+	if t.ExpectedProblem != nil {
+		t.assertProblem(tt, data)
+	}
+
+	if t.ExpectedPage != nil {
+		t.assertPage(tt, data)
+	}
+
+	if len(t.ExpectedContainsValue) > 0 {
+		t.assertContainsValues(tt, data)
+	}
+
+	if len(t.AssertChanged) > 0 || len(t.AssertUnchanged) > 0 {
+		t.assertFieldChanges(tt, received.body, data)
+	}
+
+	if t.ExpectedStruct != nil {
+		typ := reflect.TypeOf(t.ExpectedStruct)
+
+		v := reflect.New(typ)
+		if typ.Kind() == reflect.Ptr {
+			v = reflect.New(typ.Elem())
+		}
+
+		if err := json.Unmarshal(data, v.Interface()); err != nil {
+			tt.Fatalf("failed to unmarshal response into %s: %s", typ, err.Error())
+		}
+
+		if typ.Kind() != reflect.Ptr {
+			v = v.Elem()
+		}
+
+		require.Equal(tt, t.ExpectedStruct, v.Interface())
+	}
+
+	if t.DisallowUnknownFields != nil {
+		typ := reflect.TypeOf(t.DisallowUnknownFields)
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+
+		if err := dec.Decode(reflect.New(typ).Interface()); err != nil {
+			tt.Errorf("response does not conform to %s: %s", typ, err.Error())
+		}
+	}
+
+	var expectedResp string
+
+	isXML := isXMLContentType(resp.Header.Get("Content-Type"))
+
+	marshal := json.Marshal
+	if isXML {
+		marshal = xml.Marshal
+	}
+
+	if isCSVContentType(t.ExpectedContentType) && expectedResponse != nil {
+		t.csvEq(tt, expectedResponse, data)
+		return
+	}
+
+	if isFormContentType(t.ExpectedContentType) && expectedResponse != nil {
+		formEq(tt, expectedResponse, data)
+		return
+	}
+
+	switch m := expectedResponse.(type) {
+	case []byte:
+		if ct := resp.Header.Get("Content-Type"); ct != "" && !isTextContentType(ct) {
+			binaryEq(tt, m, data)
+			return
+		}
+		expectedResp = string(m)
+	case string:
+		if m == "" {
+			require.Empty(tt, string(data), "unexpected response body")
+			return
+		}
+		expectedResp = m
+	case nil:
+		return
+	case anyOf:
+		t.assertAnyOf(tt, m, data, isXML)
+		return
+	case *regexp.Regexp:
+		require.Regexp(tt, m, string(data))
+		return
+	case func() interface{}:
+		v := m()
+		data, err := marshal(v)
+		if err != nil {
+			tt.Fatalf("%s", marshalError("ExpectedResponse", v, err).Error())
+		}
+		expectedResp = string(data)
+	case *OperationRef:
+		v, err := m.resolve(t.Operations[m.Index].Returns[m.Return])
+		if err != nil {
+			tt.Fatalf("failed to resolve response: %s", err.Error())
+		}
+		data, err := marshal(v)
+		if err != nil {
+			tt.Fatalf("%s", marshalError("ExpectedResponse", v, err).Error())
+		}
+		expectedResp = string(data)
+	default:
+		data, err := marshal(m)
+		if err != nil {
+			tt.Fatalf("%s", marshalError("ExpectedResponse", m, err).Error())
+		}
+		expectedResp = string(data)
+	}
+
+	if len(data) > 0 {
+		if isXML {
+			xmlEq(tt, expectedResp, string(data))
+		} else {
+			t.jsonEq(tt, expectedResp, string(data))
+		}
+	}
+}
+
+// newRequest builds the request from the test definition
+func (t *Test) newRequest(backend Backend, baseURL string, tt testingT) *http.Request {
+	tt.Helper()
+
+	var body io.Reader
+
+	// the defaulted content type, the test is left untouched
+	contentType := t.RequestContentType
+
+	switch m := t.Request.(type) {
+	case []byte:
+		body = bytes.NewReader(m)
+	case string:
+		body = strings.NewReader(m)
+	case nil:
+		// do nothing
+	case chan []byte:
+		body = streamBody(m)
+	case <-chan []byte:
+		body = streamBody(m)
+	case *OperationRef:
+		v, err := m.resolve(t.Operations[m.Index].Args[m.Arg])
+		if err != nil {
+			tt.Fatalf("failed to resolve request: %s", err.Error())
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			tt.Fatalf("%s", marshalError("Request", v, err).Error())
+		}
+		body = bytes.NewReader(data)
+	case mergePatch, jsonPatch:
+		data, err := json.Marshal(m)
+		if err != nil {
+			tt.Fatalf("%s", marshalError("Request", m, err).Error())
+		}
+		body = bytes.NewReader(data)
+
+		if contentType == "" {
+			if _, ok := m.(mergePatch); ok {
+				contentType = MergePatchContentType
+			} else {
+				contentType = JSONPatchContentType
+			}
+		}
+	case RequestHandler:
+		b, err := m(backend, t)
+		if err != nil {
+			tt.Fatalf("failed to build request body: %s", err.Error())
+		}
+		body = b
+	default:
+		data, err := json.Marshal(m)
+		if err != nil {
+			tt.Fatalf("%s", marshalError("Request", m, err).Error())
+		}
+		body = bytes.NewReader(data)
+	}
+
+	var sigName, sigValue string
+
+	if t.SignRequest != nil {
+		var data []byte
+
+		if body != nil {
+			b, err := ioutil.ReadAll(body)
+			if err != nil {
+				tt.Fatalf("failed to read request body: %s", err.Error())
+			}
+			data = b
+			body = bytes.NewReader(data)
+		}
+
+		sigName, sigValue = t.SignRequest(data)
+	}
+
+	req, err := http.NewRequest(t.Method, baseURL+t.BasePath+t.Path, body)
+	if err != nil {
+		tt.Fatalf("failed to create request: %s", err.Error())
+	}
+	req.URL.RawQuery = t.Query.Encode()
+
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	if sigName != "" {
+		req.Header.Set(sigName, sigValue)
+	}
+
+	return req
+}
+
+// retargetRequest clones the request object pointing it at the test server
+func (t *Test) retargetRequest(baseURL string) *http.Request {
+	u, _ := url.Parse(baseURL)
+
+	req := t.RequestObject.Clone(t.RequestObject.Context())
+
+	if req.Host == req.URL.Host {
+		req.Host = ""
+	}
+
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	req.RequestURI = ""
+
+	return req
+}
+
+// registerOperations sets the operation expectations on the backends
+func (t *Test) registerOperations(backend Backend) {
+	for i, o := range t.Operations {
+		args := make([]interface{}, 0)
+		for _, a := range o.Args {
+			if isMatcher(a) {
+				args = append(args, a)
+			} else {
+				args = append(args, mock.AnythingOfType(reflect.TypeOf(a).String()))
+			}
+		}
+		returns := o.Returns
+		if returns == nil && len(o.ReturnStack) > 0 {
+			returns = o.ReturnStack[len(o.ReturnStack)-1]
+		}
+		if o.Backend != nil {
+			o.call = o.Backend.On(o.Name, args...).Return(returns...)
+		} else {
+			o.call = backend.On(o.Name, args...).Return(returns...)
+		}
+
+		if o.Times > 0 {
+			o.call.Times(o.Times)
+		}
+
+		if o.Delay > 0 {
+			o.call.After(o.Delay)
+		}
+
+		if o.WaitFor != nil {
+			o.call.WaitUntil(o.WaitFor)
+		}
+
+		if o.Panic != nil {
+			v := o.Panic
+			o.call.Run(func(mock.Arguments) {
+				panic(v)
+			})
+		}
+
+		if o.Optional || (o.MinTimes == 0 && o.MaxTimes > 0) {
+			o.call.Maybe()
+		}
+
+		t.Operations[i] = o
+	}
+}
+
+// checkRedirect returns the client redirect policy, redirects are not followed unless Redirect or FollowRedirects is set
+func (t *Test) checkRedirect() func(req *http.Request, via []*http.Request) error {
+	if t.Redirect == nil && !t.FollowRedirects {
+		return NoRedirect
+	}
+
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+
+		if t.Redirect != nil {
+			t.Redirect(req, via)
+		}
+
+		return nil
+	}
+}
+
+// ArgKey returns the ReturnMap key of the args
+func ArgKey(args ...interface{}) string {
+	keys := make([]string, 0, len(args))
+	for _, a := range args {
+		keys = append(keys, fmt.Sprint(a))
+	}
+	return strings.Join(keys, ",")
+}
+
+// argKey returns the ReturnMap key of the call args
+func (o Operation) argKey(args []interface{}) string {
+	keyed := make([]interface{}, 0, len(args))
+	for i, a := range args {
+		if i < len(o.Args) && isMatcher(o.Args[i]) {
+			continue
+		}
+		keyed = append(keyed, a)
+	}
+	return ArgKey(keyed...)
+}
+
+// retryable returns true if the status is one of the RetryOn statuses
+func (t *Test) retryable(status int) bool {
+	for _, s := range t.RetryOn {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// replayRequest clones the request with a fresh body
+func replayRequest(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return r, nil
+	}
+
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("request body cannot be replayed")
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = body
+
+	return r, nil
+}
+
+// setEnv applies the test environment and returns a func that restores the previous values
+func (t *Test) setEnv(tt testingT) func() {
+	tt.Helper()
+
+	prev := make(map[string]*string)
+
+	restore := func() {
+		for k, v := range prev {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+
+	for k, v := range t.Env {
+		if old, ok := os.LookupEnv(k); ok {
+			prev[k] = &old
+		} else {
+			prev[k] = nil
+		}
+
+		if err := os.Setenv(k, v); err != nil {
+			restore()
+			tt.Fatalf("failed to set environment variable %s: %s", k, err.Error())
+		}
+	}
+
+	return restore
+}
+
+// isMatcher returns true if the arg is a testify argument matcher
+func isMatcher(a interface{}) bool {
+	switch v := a.(type) {
+	case mock.AnythingOfTypeArgument, *mock.IsTypeArgument:
+		return true
+	case string:
+		return v == mock.Anything
+	}
+
+	// mock.MatchedBy returns an unexported type
+	return reflect.TypeOf(a).String() == "mock.argumentMatcher"
+}
+
+// assertCallCounts checks the total backend and operation call count bounds
+func (t *Test) assertCallCounts(backend Backend, tt testingT) {
+	tt.Helper()
+
+	if n := len(t.backendCalls(backend)); t.ExpectedTotalCalls > 0 && n != t.ExpectedTotalCalls {
+		tt.Errorf("backend called %d times, expected %d", n, t.ExpectedTotalCalls)
+	}
+
+	for _, o := range t.Operations {
+		if o.MinTimes == 0 && o.MaxTimes == 0 {
+			continue
+		}
+
+		calls := t.backendCalls(backend)
+		if o.Backend != nil {
+			calls = o.Backend.Calls
+		}
+
+		n := 0
+		for _, c := range calls {
+			if c.Method == o.Name {
+				n++
+			}
+		}
+
+		if o.MinTimes > 0 && n < o.MinTimes {
+			tt.Errorf("operation %s called %d times, expected at least %d", o.Name, n, o.MinTimes)
+		}
+
+		if o.MaxTimes > 0 && n > o.MaxTimes {
+			tt.Errorf("operation %s called %d times, expected at most %d", o.Name, n, o.MaxTimes)
+		}
+	}
+}
+
+// responseStatuses returns the sorted ExpectedResponses statuses
+func (t *Test) responseStatuses() []int {
+	statuses := make([]int, 0, len(t.ExpectedResponses))
+	for s := range t.ExpectedResponses {
+		statuses = append(statuses, s)
+	}
+	sort.Ints(statuses)
+
+	return statuses
+}
+
+// backendCalls returns the calls recorded by the backend, a backend not embedding Mock or mock.Mock is
+// resolved from its registered operations
+func (t *Test) backendCalls(backend Backend) []mock.Call {
+	if calls, ok := recordedCalls(backend); ok {
+		return calls
+	}
+
+	for _, o := range t.Operations {
+		if o.Backend == nil && o.call != nil && o.call.Parent != nil {
+			return o.call.Parent.Calls
+		}
+	}
+
+	// no operations are registered so any call fails as unexpected
+	return nil
+}
+
+// recordedCalls returns the calls recorded by a Mock, a mock.Mock or a backend embedding Mock
+func recordedCalls(backend Backend) ([]mock.Call, bool) {
+	switch m := backend.(type) {
+	case boundBackend:
+		return m.recordedCalls(), true
+	case *mock.Mock:
+		return m.Calls, true
+	}
+	return nil, false
+}
+
+// resetBackend clears the expectations and calls of a Mock, a mock.Mock or a backend embedding Mock
+func resetBackend(backend Backend) bool {
+	switch m := backend.(type) {
+	case boundBackend:
+		m.reset()
+	case *mock.Mock:
+		m.ExpectedCalls = nil
+		m.Calls = nil
+	default:
+		return false
+	}
+	return true
+}
+
+// streamBody pipes each chunk from the channel to the returned reader
+func streamBody(ch <-chan []byte) io.Reader {
+	pr, pw := io.Pipe()
+
+	go func() {
+		var err error
+
+		for b := range ch {
+			// keep draining the channel so the producer never blocks
+			if err == nil {
+				_, err = pw.Write(b)
+			}
+		}
+
+		pw.Close()
+	}()
+
+	return pr
+}
+
+// Observe wraps the handler to record the request it receives, use it inside
+// any middleware to observe the effective request
+func (t *Test) Observe(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.ObservedMethod = r.Method
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequestState returns the test state attached to the request in Setup
+func RequestState(r *http.Request) map[string]interface{} {
+	if s, ok := r.Context().Value(stateKey{}).(map[string]interface{}); ok {
+		return s
+	}
+	return nil
+}
+
+// Select returns a copy of the reference pointing at the dotted field path
+func (r *OperationRef) Select(field string) *OperationRef {
+	ref := *r
+	ref.Field = field
+	return &ref
+}
+
+// At returns a copy of the reference pointing at the slice element i
+func (r *OperationRef) At(i int) *OperationRef {
+	ref := *r
+	ref.Element = &i
+	return &ref
+}
+
+// resolve walks the element and field path of the reference from v
+func (r *OperationRef) resolve(v interface{}) (interface{}, error) {
+	val := reflect.ValueOf(v)
+
+	if r.Element != nil {
+		for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+			if val.IsNil() {
+				return nil, fmt.Errorf("element %d: nil value", *r.Element)
+			}
+			val = val.Elem()
+		}
+
+		if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+			return nil, fmt.Errorf("element %d: %T is not a slice or array", *r.Element, v)
+		}
+
+		if *r.Element < 0 || *r.Element >= val.Len() {
+			return nil, fmt.Errorf("element %d: index out of range [0:%d]", *r.Element, val.Len())
+		}
+
+		val = val.Index(*r.Element)
+	}
+
+	if r.Field == "" {
+		if !val.IsValid() {
+			return nil, nil
+		}
+		return val.Interface(), nil
+	}
+
+	for _, name := range strings.Split(r.Field, ".") {
+		for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+			if val.IsNil() {
+				return nil, fmt.Errorf("field %q: nil value at %q", r.Field, name)
+			}
+			val = val.Elem()
+		}
+
+		if !val.IsValid() {
+			return nil, fmt.Errorf("field %q: nil value at %q", r.Field, name)
+		}
+
+		if val.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("field %q: %s is not a struct at %q", r.Field, val.Type(), name)
+		}
+
+		sf, ok := val.Type().FieldByName(name)
+		if !ok {
+			return nil, fmt.Errorf("field %q: %s has no field %q", r.Field, val.Type(), name)
+		}
+
+		if sf.PkgPath != "" {
+			return nil, fmt.Errorf("field %q: %s field %q is unexported", r.Field, val.Type(), name)
+		}
+
+		val = val.FieldByIndex(sf.Index)
+	}
+
+	return val.Interface(), nil
+}
+
+// Called tells the mock object that a method has been called, and gets an array
+// of arguments to return.  Panics if the call is unexpected (i.e. not preceded by
+// appropriate .On .Return() calls)
+// If Call.WaitFor is set, blocks until the channel is closed or receives a message.
+func (m *Mock) Called(arguments ...interface{}) mock.Arguments {
+	// get the calling function's name
+	pc, _, _, ok := runtime.Caller(1)
+	if !ok {
+		panic("Couldn't get the caller information")
+	}
+	functionPath := runtime.FuncForPC(pc).Name()
+	//Next four lines are required to use GCCGO function naming conventions.
+	//For Ex:  github_com_docker_libkv_store_mock.WatchTree.pN39_github_com_docker_libkv_store_mock.Mock
+	//uses interface information unlike golang github.com/docker/libkv/store/mock.(*Mock).WatchTree
+	//With GCCGO we need to remove interface information starting from pN<dd>.
+	re := regexp.MustCompile("\\.pN\\d+_")
+	if re.MatchString(functionPath) {
+		functionPath = re.Split(functionPath, -1)[0]
+	}
+	parts := strings.Split(functionPath, ".")
+	functionName := parts[len(parts)-1]
+	return m.MethodCalled(functionName, arguments...)
+}
+
+// MethodCalled wraps mock.MethodCalled to handle return stacks
+func (m *Mock) MethodCalled(methodName string, arguments ...interface{}) mock.Arguments {
+	// called outside of a test run
+	if m.t == nil {
+		return m.Mock.MethodCalled(methodName, arguments...)
+	}
+
+	// the mapped returns are picked per call, leaving the registered returns for the other args
+	var mapped []interface{}
+	var isMapped, declared bool
+
+	m.mu.Lock()
+	for i, op := range m.t.Operations {
+		if op.Name == methodName {
+			declared = true
+			if len(op.ReturnStack) > 0 {
+				n := len(op.ReturnStack) - 1
+				op.call.ReturnArguments = mock.Arguments(op.ReturnStack[0])
+				op.ReturnStack = op.ReturnStack[n:]
 
 				m.t.Operations[i] = op
 			}
+			mapped, isMapped = op.ReturnMap[op.argKey(arguments)]
 			break
 		}
 	}
+	m.mu.Unlock()
+
+	// declared operations are never served from the defaults
+	if rets, ok := m.t.DefaultReturns[methodName]; ok && !declared {
+		m.registerDefault(methodName, len(arguments), rets)
+	}
+
+	rets := m.Mock.MethodCalled(methodName, arguments...)
+
+	if isMapped {
+		rets = mock.Arguments(mapped)
+	}
+
+	if m.t.timing != nil {
+		m.t.timing.called(methodName)
+	}
+
+	return rets
+}
+
+// bind sets the test the mock is called for
+func (m *Mock) bind(t *Test) {
+	m.t = t
+	m.resetDefaults()
+}
+
+// recordedCalls returns the calls recorded by the mock
+func (m *Mock) recordedCalls() []mock.Call {
+	return m.Calls
+}
+
+// reset clears the expectations and calls of the mock
+func (m *Mock) reset() {
+	m.ExpectedCalls = nil
+	m.Calls = nil
+}
+
+// registerDefault registers the optional default operation for the method and arg count once, after the test
+// operations so they take precedence
+func (m *Mock) registerDefault(methodName string, n int, returns []interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := fmt.Sprintf("%s/%d", methodName, n)
+	if _, ok := m.defaults[key]; ok {
+		return
+	}
+
+	if m.defaults == nil {
+		m.defaults = make(map[string]*mock.Call)
+	}
+
+	args := make([]interface{}, n)
+	for i := range args {
+		args[i] = mock.Anything
+	}
+
+	m.defaults[key] = m.On(methodName, args...).Return(returns...).Maybe()
+}
+
+// resetDefaults removes the registered default operations from the expected calls
+func (m *Mock) resetDefaults() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.defaults) == 0 {
+		return
+	}
+
+	registered := make(map[*mock.Call]bool, len(m.defaults))
+	for _, c := range m.defaults {
+		registered[c] = true
+	}
+
+	calls := make([]*mock.Call, 0, len(m.ExpectedCalls))
+	for _, c := range m.ExpectedCalls {
+		if !registered[c] {
+			calls = append(calls, c)
+		}
+	}
 
-	return m.Mock.MethodCalled(methodName, arguments...)
+	m.ExpectedCalls = calls
+	m.defaults = nil
 }
 
 // BeginQuery returns an intialized values
@@ -0,0 +1,2312 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type (
+	// stripWriter removes the headers from the response before it is written
+	stripWriter struct {
+		http.ResponseWriter
+		headers []string
+	}
+
+	// tenantKey is the context key of the tenant injected by ContextValues
+	tenantKey struct{}
+)
+
+func (w *stripWriter) WriteHeader(status int) {
+	for _, h := range w.headers {
+		w.Header().Del(h)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func TestOperationRefSelect(t *testing.T) {
+	u := &user{ID: "u1", Address: address{City: "Paris"}, secret: "s"}
+
+	handler := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var id string
+			json.NewDecoder(r.Body).Decode(&id)
+
+			u, _ := store.Get(id)
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(u.Address.City)
+		})
+	}
+
+	ops := []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{u, nil}}}
+
+	runCases(t, handler, []testCase{
+		{
+			name: "field",
+			test: Test{
+				Operations:       ops,
+				Method:           http.MethodPost,
+				Path:             "/users",
+				Request:          OperationArg(0),
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: OperationReturn(0).Select("Address.City"),
+			},
+		},
+		{
+			name: "unexported",
+			test: Test{
+				Operations:       ops,
+				Method:           http.MethodPost,
+				Path:             "/users",
+				Request:          OperationArg(0),
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: OperationReturn(0).Select("secret"),
+			},
+			failure: `field "secret" is unexported`,
+		},
+		{
+			name: "missing",
+			test: Test{
+				Operations:       ops,
+				Method:           http.MethodPost,
+				Path:             "/users",
+				Request:          OperationArg(0),
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: OperationReturn(0).Select("Address.Zip"),
+			},
+			failure: `has no field "Zip"`,
+		},
+	})
+}
+
+func TestNoRequestBody(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "get",
+			test: Test{Method: http.MethodGet, Path: "/users/1", NoRequestBody: true, ExpectedStatus: http.StatusNoContent},
+		},
+		{
+			name: "delete",
+			test: Test{Method: http.MethodDelete, Path: "/users/1", NoRequestBody: true, ExpectedStatus: http.StatusNoContent},
+		},
+		{
+			name: "stray body",
+			test: Test{
+				Method:         http.MethodGet,
+				Path:           "/users/1",
+				Request:        map[string]string{"id": "1"},
+				NoRequestBody:  true,
+				ExpectedStatus: http.StatusNoContent,
+			},
+			failure: "request body set for GET with NoRequestBody",
+		},
+		{
+			name: "stray body without NoRequestBody",
+			test: Test{
+				Method:         http.MethodGet,
+				Path:           "/users/1",
+				Request:        map[string]string{"id": "1"},
+				ExpectedStatus: http.StatusNoContent,
+			},
+			failure: "request body set for GET without AllowRequestBody",
+		},
+		{
+			name: "allowed body",
+			test: Test{
+				Method:           http.MethodGet,
+				Path:             "/users/1",
+				Request:          map[string]string{"id": "1"},
+				AllowRequestBody: true,
+				ExpectedStatus:   http.StatusNoContent,
+			},
+		},
+		{
+			name: "body sent",
+			test: Test{
+				Method: http.MethodGet,
+				Path:   "/users/1",
+				Setup: func(r *http.Request) {
+					r.Body = ioutil.NopCloser(strings.NewReader("{}"))
+					r.ContentLength = 2
+				},
+				NoRequestBody:  true,
+				ExpectedStatus: http.StatusNoContent,
+			},
+			failure: "unexpected request body",
+		},
+	})
+}
+
+func TestState(t *testing.T) {
+	echo := func(header string) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-Id", r.Header.Get(header))
+		}))
+	}
+
+	test := Test{
+		Method: http.MethodGet,
+		Path:   "/ping",
+		Setup: func(r *http.Request) {
+			id := strconv.Itoa(rand.Int())
+			RequestState(r)["id"] = id
+			r.Header.Set("X-Request-Id", id)
+		},
+		ExpectedStatus: http.StatusOK,
+		ValidateResponse: func(t *Test, resp *http.Response, body []byte) error {
+			if id := resp.Header.Get("X-Request-Id"); id != t.State["id"] {
+				return fmt.Errorf("request id %q, expected %q", id, t.State["id"])
+			}
+			return nil
+		},
+	}
+
+	runCases(t, echo("X-Request-Id"), []testCase{
+		{name: "shared", test: test},
+		{name: "mismatch", test: test, handler: echo("X-Other"), failure: "failed to validate response: request id"},
+	})
+}
+
+func TestHead(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "42")
+		w.Header().Set("ETag", `"v1"`)
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "headers",
+			test: Test{
+				Method:          http.MethodHead,
+				Path:            "/files/1",
+				ExpectedStatus:  http.StatusOK,
+				ExpectedHeaders: map[string]string{"Content-Length": "^42$", "ETag": `^"v1"$`},
+			},
+		},
+		{
+			// the body comparison is skipped
+			name: "response ignored",
+			test: Test{
+				Method:           http.MethodHead,
+				Path:             "/files/1",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: map[string]int{"size": 42},
+			},
+		},
+		{
+			name: "header mismatch",
+			test: Test{
+				Method:          http.MethodHead,
+				Path:            "/files/1",
+				ExpectedStatus:  http.StatusOK,
+				ExpectedHeaders: map[string]string{"Content-Length": "^43$"},
+			},
+			failure: "Expect \"42\" to match \"^43$\"",
+		},
+	})
+}
+
+func TestExpectedLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "", 0)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Printf("handled %s %s", r.Method, r.URL.Path)
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "logged",
+			test: Test{
+				Method:         http.MethodGet,
+				Path:           "/things",
+				ExpectedStatus: http.StatusOK,
+				LogOutput:      buf,
+				ExpectedLog:    `handled GET /things`,
+			},
+		},
+		{
+			name: "mismatch",
+			test: Test{
+				Method:         http.MethodGet,
+				Path:           "/things",
+				ExpectedStatus: http.StatusOK,
+				LogOutput:      buf,
+				ExpectedLog:    `handled DELETE /things`,
+			},
+			failure: "to match \"handled DELETE /things\"",
+		},
+		{
+			name: "no output",
+			test: Test{
+				Method:         http.MethodGet,
+				Path:           "/things",
+				ExpectedStatus: http.StatusOK,
+				ExpectedLog:    `handled`,
+			},
+			failure: "ExpectedLog requires LogOutput",
+		},
+	})
+}
+
+func TestSignRequest(t *testing.T) {
+	key := []byte("secret")
+
+	sign := func(body []byte) string {
+		m := hmac.New(sha256.New, key)
+		m.Write(body)
+		return "sha256=" + hex.EncodeToString(m.Sum(nil))
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !hmac.Equal([]byte(sign(body)), []byte(r.Header.Get("X-Hub-Signature-256"))) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "signed",
+			test: Test{
+				Method:         http.MethodPost,
+				Path:           "/hooks",
+				Request:        map[string]string{"event": "ping"},
+				ExpectedStatus: http.StatusAccepted,
+				SignRequest: func(body []byte) (string, string) {
+					return "X-Hub-Signature-256", sign(body)
+				},
+			},
+		},
+		{
+			// a signature over other bytes than sent is rejected
+			name: "other bytes",
+			test: Test{
+				Method:         http.MethodPost,
+				Path:           "/hooks",
+				Request:        map[string]string{"event": "ping"},
+				ExpectedStatus: http.StatusAccepted,
+				SignRequest: func(body []byte) (string, string) {
+					return "X-Hub-Signature-256", sign(append(body, ' '))
+				},
+			},
+			failure: "Not equal",
+		},
+	})
+}
+
+func TestExpectedContentTypes(t *testing.T) {
+	type item struct {
+		XMLName xml.Name `xml:"item" json:"-"`
+		Name    string   `xml:"name" json:"name"`
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		it := item{Name: "widget"}
+
+		switch r.Header.Get("Accept") {
+		case "application/xml":
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte("<?xml version=\"1.0\"?>\n<item>\n  <name>widget</name>\n</item>\n"))
+		case "text/plain":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(it.Name))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(it)
+		}
+	})
+
+	accept := func(ct string) func(r *http.Request) {
+		return func(r *http.Request) {
+			r.Header.Set("Accept", ct)
+		}
+	}
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "xml",
+			test: Test{
+				Method:               http.MethodGet,
+				Path:                 "/items/1",
+				Setup:                accept("application/xml"),
+				ExpectedStatus:       http.StatusOK,
+				ExpectedContentTypes: []string{"application/json", "application/xml"},
+				ExpectedResponse:     item{Name: "widget"},
+			},
+		},
+		{
+			name: "json",
+			test: Test{
+				Method:               http.MethodGet,
+				Path:                 "/items/1",
+				Setup:                accept("application/json"),
+				ExpectedStatus:       http.StatusOK,
+				ExpectedContentTypes: []string{"application/json", "application/xml"},
+				ExpectedResponse:     item{Name: "widget"},
+			},
+		},
+		{
+			name: "not listed",
+			test: Test{
+				Method:               http.MethodGet,
+				Path:                 "/items/1",
+				Setup:                accept("text/plain"),
+				ExpectedStatus:       http.StatusOK,
+				ExpectedContentTypes: []string{"application/json", "application/xml"},
+				ExpectedResponse:     item{Name: "widget"},
+			},
+			failure: `does not contain "text/plain"`,
+		},
+	})
+}
+
+func TestOperationDelay(t *testing.T) {
+	handler := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), 20*time.Millisecond)
+			defer cancel()
+
+			done := make(chan struct{})
+			go func() {
+				store.Get("u1")
+				close(done)
+			}()
+
+			select {
+			case <-done:
+				w.WriteHeader(http.StatusOK)
+			case <-ctx.Done():
+				w.WriteHeader(http.StatusGatewayTimeout)
+
+				// the backend call must complete before the handler returns and the calls are asserted
+				<-done
+			}
+		})
+	}
+
+	runCases(t, handler, []testCase{
+		{
+			name: "slow",
+			test: Test{
+				Operations:     []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{nil, nil}, Delay: 200 * time.Millisecond}},
+				Method:         http.MethodGet,
+				Path:           "/users/u1",
+				ExpectedStatus: http.StatusGatewayTimeout,
+			},
+		},
+		{
+			name: "fast",
+			test: Test{
+				Operations:     []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{nil, nil}}},
+				Method:         http.MethodGet,
+				Path:           "/users/u1",
+				ExpectedStatus: http.StatusOK,
+			},
+		},
+	})
+}
+
+func TestStreamRequest(t *testing.T) {
+	chunks := make(chan []byte)
+	sent := make(chan struct{})
+
+	go func() {
+		for _, c := range []string{"a\n", "b\n", "c\n"} {
+			time.Sleep(10 * time.Millisecond)
+			chunks <- []byte(c)
+		}
+		close(sent)
+		close(chunks)
+	}()
+
+	var early bool
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var lines []string
+
+		s := bufio.NewScanner(r.Body)
+		for s.Scan() {
+			if len(lines) == 0 {
+				select {
+				case <-sent:
+				default:
+					early = true
+				}
+			}
+			lines = append(lines, s.Text())
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lines)
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "streamed",
+			test: Test{
+				Method:           http.MethodPost,
+				Path:             "/lines",
+				Request:          chunks,
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: []string{"a", "b", "c"},
+			},
+			check: func(tt *testing.T, test *Test, store *userStore) {
+				if !early {
+					tt.Errorf("the first chunk was not read before the stream closed")
+				}
+			},
+		},
+	})
+}
+
+func TestOperationRefAt(t *testing.T) {
+	users := []*user{{ID: "u1", Name: "Ann"}, {ID: "u2", Name: "Bob"}}
+
+	handler := func(i int) func(*userStore) http.Handler {
+		return func(store *userStore) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				l, _ := store.List()
+
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(l[i])
+			})
+		}
+	}
+
+	ops := []Operation{{Name: "List", Returns: Returns{users, nil}}}
+
+	runCases(t, handler(1), []testCase{
+		{
+			name: "element",
+			test: Test{
+				Operations:       ops,
+				Method:           http.MethodGet,
+				Path:             "/users",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: OperationReturn(0).At(1),
+			},
+		},
+		{
+			name: "wrong element",
+			test: Test{
+				Operations:       ops,
+				Method:           http.MethodGet,
+				Path:             "/users",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: OperationReturn(0).At(1),
+			},
+			handler: handler(0),
+			failure: "Bob",
+		},
+		{
+			name: "out of range",
+			test: Test{
+				Operations:       ops,
+				Method:           http.MethodGet,
+				Path:             "/users",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: OperationReturn(0).At(2),
+			},
+			failure: "element 2: index out of range [0:2]",
+		},
+	})
+}
+
+func TestOperationTimesBounds(t *testing.T) {
+	handler := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+			for i := 0; i < n; i++ {
+				store.Audit("retry")
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+
+	runCases(t, handler, []testCase{
+		{
+			name: "at least, exact",
+			test: Test{
+				Operations:     []Operation{{Name: "Audit", Args: Args{"retry"}, MinTimes: 2}},
+				Method:         http.MethodPost,
+				Path:           "/retry",
+				Query:          url.Values{"n": []string{"2"}},
+				ExpectedStatus: http.StatusNoContent,
+			},
+		},
+		{
+			name: "at least, more",
+			test: Test{
+				Operations:     []Operation{{Name: "Audit", Args: Args{"retry"}, MinTimes: 2}},
+				Method:         http.MethodPost,
+				Path:           "/retry",
+				Query:          url.Values{"n": []string{"3"}},
+				ExpectedStatus: http.StatusNoContent,
+			},
+		},
+		{
+			name: "at least, fewer",
+			test: Test{
+				Operations:     []Operation{{Name: "Audit", Args: Args{"retry"}, MinTimes: 2}},
+				Method:         http.MethodPost,
+				Path:           "/retry",
+				Query:          url.Values{"n": []string{"1"}},
+				ExpectedStatus: http.StatusNoContent,
+			},
+			failure: "operation Audit called 1 times, expected at least 2",
+		},
+		{
+			name: "at most, none",
+			test: Test{
+				Operations:     []Operation{{Name: "Audit", Args: Args{"retry"}, MaxTimes: 2}},
+				Method:         http.MethodPost,
+				Path:           "/retry",
+				Query:          url.Values{"n": []string{"0"}},
+				ExpectedStatus: http.StatusNoContent,
+			},
+		},
+		{
+			name: "at most, exact",
+			test: Test{
+				Operations:     []Operation{{Name: "Audit", Args: Args{"retry"}, MaxTimes: 2}},
+				Method:         http.MethodPost,
+				Path:           "/retry",
+				Query:          url.Values{"n": []string{"2"}},
+				ExpectedStatus: http.StatusNoContent,
+			},
+		},
+		{
+			name: "at most, more",
+			test: Test{
+				Operations:     []Operation{{Name: "Audit", Args: Args{"retry"}, MaxTimes: 2}},
+				Method:         http.MethodPost,
+				Path:           "/retry",
+				Query:          url.Values{"n": []string{"3"}},
+				ExpectedStatus: http.StatusNoContent,
+			},
+			failure: "operation Audit called 3 times, expected at most 2",
+		},
+	})
+}
+
+func TestRequestObject(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"method": r.Method,
+			"uri":    r.URL.RequestURI(),
+			"header": r.Header.Get("X-Token"),
+			"body":   string(body),
+		})
+	})
+
+	req := httptest.NewRequest("PURGE", "http://cache.example.com/items?key=1", strings.NewReader("raw body"))
+	req.Header.Set("X-Token", "a\tb")
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "sent as is",
+			test: Test{
+				RequestObject:  req,
+				ExpectedStatus: http.StatusOK,
+				ExpectedResponse: map[string]string{
+					"method": "PURGE",
+					"uri":    "/items?key=1",
+					"header": "a\tb",
+					"body":   "raw body",
+				},
+			},
+		},
+	})
+}
+
+func TestExpectPanic(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		panic("nil user")
+	})
+
+	var v interface{}
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "panic",
+			test: Test{
+				Method:         http.MethodGet,
+				Path:           "/panic",
+				ExpectedStatus: http.StatusInternalServerError,
+				ExpectPanic:    true,
+				OnPanic:        func(p interface{}) { v = p },
+			},
+			check: func(tt *testing.T, test *Test, store *userStore) {
+				if v != "nil user" {
+					tt.Errorf("unexpected panic value %v", v)
+				}
+			},
+		},
+		{
+			name: "error response",
+			test: Test{
+				Method:         http.MethodGet,
+				Path:           "/error",
+				ExpectedStatus: http.StatusInternalServerError,
+				ExpectPanic:    true,
+			},
+			failure: "expected handler panic",
+		},
+	})
+}
+
+func TestServerName(t *testing.T) {
+	tenants := map[string]string{
+		"acme.example.com":   "acme",
+		"globex.example.com": "globex",
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil {
+			w.WriteHeader(http.StatusUpgradeRequired)
+			return
+		}
+
+		tenant, ok := tenants[r.TLS.ServerName]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"tenant": tenant})
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "acme",
+			test: Test{
+				Method:           http.MethodGet,
+				Path:             "/tenant",
+				ServerName:       "acme.example.com",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: map[string]string{"tenant": "acme"},
+			},
+		},
+		{
+			name: "globex",
+			test: Test{
+				Method:           http.MethodGet,
+				Path:             "/tenant",
+				ServerName:       "globex.example.com",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: map[string]string{"tenant": "globex"},
+			},
+		},
+		{
+			name: "unknown",
+			test: Test{
+				Method:         http.MethodGet,
+				Path:           "/tenant",
+				ServerName:     "initech.example.com",
+				ExpectedStatus: http.StatusNotFound,
+			},
+		},
+	})
+}
+
+func TestHost(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"site": strings.Split(r.Host, ".")[0]})
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "blog",
+			test: Test{
+				Method:           http.MethodGet,
+				Path:             "/",
+				Host:             "blog.example.com",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: map[string]string{"site": "blog"},
+			},
+		},
+		{
+			name: "shop",
+			test: Test{
+				Method:           http.MethodGet,
+				Path:             "/",
+				Host:             "shop.example.com",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: map[string]string{"site": "shop"},
+			},
+		},
+		{
+			name: "default host",
+			test: Test{
+				Method:           http.MethodGet,
+				Path:             "/",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: map[string]string{"site": "blog"},
+			},
+			failure: `"127"`,
+		},
+	})
+}
+
+func TestExpectedResponseRegexp(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><form><input name="csrf" value="%d"></form></html>`, time.Now().UnixNano())
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "match",
+			test: Test{
+				Method:           http.MethodGet,
+				Path:             "/login",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: regexp.MustCompile(`<input name="csrf" value="\d+">`),
+			},
+		},
+		{
+			name: "mismatch",
+			test: Test{
+				Method:           http.MethodGet,
+				Path:             "/login",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: regexp.MustCompile(`<input name="csrf" value="[a-f]+">`),
+			},
+			failure: `to match "<input name="csrf" value="[a-f]+">"`,
+		},
+	})
+}
+
+func TestOptionalOperation(t *testing.T) {
+	handler := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("audit") != "" {
+				store.Audit("viewed")
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+
+	runCases(t, handler, []testCase{
+		{
+			name: "called",
+			test: Test{
+				Operations:     []Operation{{Name: "Audit", Args: Args{"viewed"}, Optional: true}},
+				Method:         http.MethodGet,
+				Path:           "/users/u1",
+				Query:          url.Values{"audit": []string{"1"}},
+				ExpectedStatus: http.StatusNoContent,
+			},
+		},
+		{
+			name: "not called",
+			test: Test{
+				Operations:     []Operation{{Name: "Audit", Args: Args{"viewed"}, Optional: true}},
+				Method:         http.MethodGet,
+				Path:           "/users/u1",
+				ExpectedStatus: http.StatusNoContent,
+			},
+		},
+		{
+			name: "required",
+			test: Test{
+				Operations:     []Operation{{Name: "Audit", Args: Args{"viewed"}}},
+				Method:         http.MethodGet,
+				Path:           "/users/u1",
+				ExpectedStatus: http.StatusNoContent,
+			},
+			failure: "0 out of 1 expectation(s) were met",
+		},
+	})
+}
+
+func TestExpectedTotalCalls(t *testing.T) {
+	handler := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+			for i := 0; i < n; i++ {
+				store.Get("u1")
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+
+	runCases(t, handler, []testCase{
+		{
+			name: "single call",
+			test: Test{
+				Operations:         []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{nil, nil}, MinTimes: 1}},
+				Method:             http.MethodGet,
+				Path:               "/users/u1",
+				Query:              url.Values{"n": []string{"1"}},
+				ExpectedStatus:     http.StatusNoContent,
+				ExpectedTotalCalls: 1,
+			},
+		},
+		{
+			name: "extra call",
+			test: Test{
+				Operations:         []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{nil, nil}, MinTimes: 1}},
+				Method:             http.MethodGet,
+				Path:               "/users/u1",
+				Query:              url.Values{"n": []string{"2"}},
+				ExpectedStatus:     http.StatusNoContent,
+				ExpectedTotalCalls: 1,
+			},
+			failure: "backend called 2 times, expected 1",
+		},
+	})
+}
+
+func TestEnv(t *testing.T) {
+	const flag = "LITMUS_TEST_FEATURE_BETA"
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if os.Getenv(flag) != "on" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	restored := func(expected string, set bool) func(tt *testing.T, test *Test, store *userStore) {
+		return func(tt *testing.T, test *Test, store *userStore) {
+			if v, ok := os.LookupEnv(flag); v != expected || ok != set {
+				tt.Errorf("%s not restored after the test: %q", flag, v)
+			}
+		}
+	}
+
+	os.Unsetenv(flag)
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name:  "unset",
+			test:  Test{Method: http.MethodGet, Path: "/beta", Env: map[string]string{flag: "on"}, ExpectedStatus: http.StatusNoContent},
+			check: restored("", false),
+		},
+	})
+
+	os.Setenv(flag, "off")
+	defer os.Unsetenv(flag)
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name:    "failed",
+			test:    Test{Method: http.MethodGet, Path: "/beta", Env: map[string]string{flag: "on"}, ExpectedStatus: http.StatusNotFound},
+			failure: "actual  : 204",
+			check:   restored("off", true),
+		},
+		{
+			name: "inherited",
+			test: Test{Method: http.MethodGet, Path: "/beta", ExpectedStatus: http.StatusNotFound},
+		},
+	})
+}
+
+func TestHeadersWithoutResponse(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/users/u2")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("unchecked body"))
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "headers only",
+			test: Test{
+				Method:          http.MethodPost,
+				Path:            "/users",
+				ExpectedStatus:  http.StatusCreated,
+				ExpectedHeaders: map[string]string{"Location": "^/users/u2$"},
+			},
+		},
+		{
+			name: "header mismatch",
+			test: Test{
+				Method:          http.MethodPost,
+				Path:            "/users",
+				ExpectedStatus:  http.StatusCreated,
+				ExpectedHeaders: map[string]string{"Location": "^/users/u1$"},
+			},
+			failure: `Expect "/users/u2" to match "^/users/u1$"`,
+		},
+	})
+}
+
+func TestChunkedRequest(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var u user
+		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"length":   r.ContentLength,
+			"encoding": r.TransferEncoding,
+			"id":       u.ID,
+		})
+	})
+
+	chunked := map[string]interface{}{"length": -1, "encoding": []string{"chunked"}, "id": "u1"}
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "chunked",
+			test: Test{
+				Method:           http.MethodPost,
+				Path:             "/users",
+				Request:          &user{ID: "u1"},
+				ChunkedRequest:   true,
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: chunked,
+			},
+		},
+		{
+			name: "content length",
+			test: Test{
+				Method:           http.MethodPost,
+				Path:             "/users",
+				Request:          &user{ID: "u1"},
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: chunked,
+			},
+			failure: `$.encoding: expected ["chunked"], got null`,
+		},
+	})
+}
+
+func TestExpectedStruct(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{ "Name": "Ann",  "Address": {"City": "Oslo"}, "ID": "u1", "Role": "admin" }`))
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "value",
+			test: Test{
+				Method:         http.MethodGet,
+				Path:           "/users/u1",
+				ExpectedStatus: http.StatusOK,
+				ExpectedStruct: user{ID: "u1", Name: "Ann", Address: address{City: "Oslo"}},
+			},
+		},
+		{
+			name: "pointer",
+			test: Test{
+				Method:         http.MethodGet,
+				Path:           "/users/u1",
+				ExpectedStatus: http.StatusOK,
+				ExpectedStruct: &user{ID: "u1", Name: "Ann", Address: address{City: "Oslo"}},
+			},
+		},
+		{
+			name: "mismatch",
+			test: Test{
+				Method:         http.MethodGet,
+				Path:           "/users/u1",
+				ExpectedStatus: http.StatusOK,
+				ExpectedStruct: &user{ID: "u1", Name: "Ann", Address: address{City: "Paris"}},
+			},
+			failure: `Address:litmus.address{City:"Oslo"}`,
+		},
+	})
+}
+
+func TestRetryOn(t *testing.T) {
+	handler := func(failures int) func(*userStore) http.Handler {
+		return func(store *userStore) http.Handler {
+			var lock sync.Mutex
+			var n int
+
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				store.Audit("retry")
+
+				lock.Lock()
+				defer lock.Unlock()
+
+				if n++; n <= failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			})
+		}
+	}
+
+	runCases(t, handler(2), []testCase{
+		{
+			name: "recovered",
+			test: Test{
+				Operations:     []Operation{{Name: "Audit", Args: Args{"retry"}, MinTimes: 3, MaxTimes: 3}},
+				Method:         http.MethodPost,
+				Path:           "/jobs",
+				RetryOn:        []int{http.StatusServiceUnavailable},
+				MaxRetries:     2,
+				ExpectedStatus: http.StatusNoContent,
+			},
+		},
+		{
+			name: "retries exhausted",
+			test: Test{
+				Operations:     []Operation{{Name: "Audit", Args: Args{"retry"}, MinTimes: 2, MaxTimes: 2}},
+				Method:         http.MethodPost,
+				Path:           "/jobs",
+				RetryOn:        []int{http.StatusServiceUnavailable},
+				MaxRetries:     1,
+				ExpectedStatus: http.StatusNoContent,
+			},
+			failure: "actual  : 503",
+		},
+		{
+			name: "exact times",
+			test: Test{
+				Operations:     []Operation{{Name: "Audit", Args: Args{"retry"}, Times: 3}},
+				Method:         http.MethodPost,
+				Path:           "/jobs",
+				RetryOn:        []int{http.StatusServiceUnavailable},
+				MaxRetries:     2,
+				ExpectedStatus: http.StatusNoContent,
+			},
+			failure: "operation Audit Times is exact, retried requests may call it again, use MaxTimes",
+		},
+	})
+}
+
+func TestForbiddenHeaders(t *testing.T) {
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Powered-By", "php/5.6")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	strip := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.ServeHTTP(&stripWriter{ResponseWriter: w, headers: []string{"X-Powered-By"}}, r)
+	})
+
+	runCases(t, stateless(strip), []testCase{
+		{
+			name: "stripped",
+			test: Test{
+				Method:           http.MethodGet,
+				Path:             "/",
+				ExpectedStatus:   http.StatusNoContent,
+				ForbiddenHeaders: []string{"X-Powered-By", "Server"},
+			},
+		},
+		{
+			name: "present",
+			test: Test{
+				Method:           http.MethodGet,
+				Path:             "/",
+				ExpectedStatus:   http.StatusNoContent,
+				ForbiddenHeaders: []string{"X-Powered-By", "Server"},
+			},
+			handler: stateless(app),
+			failure: `forbidden response header X-Powered-By present: "php/5.6"`,
+		},
+	})
+}
+
+func TestOperationWaitFor(t *testing.T) {
+	release := make(chan time.Time)
+	waiting := make(chan struct{})
+
+	var pending bool
+
+	handler := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var u *user
+
+			done := make(chan struct{})
+			go func() {
+				u, _ = store.Get("u1")
+				close(done)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(20 * time.Millisecond):
+				pending = true
+			}
+
+			close(waiting)
+			<-done
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(u)
+		})
+	}
+
+	go func() {
+		<-waiting
+		close(release)
+	}()
+
+	runCases(t, handler, []testCase{
+		{
+			name: "released",
+			test: Test{
+				Operations:       []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}, WaitFor: release}},
+				Method:           http.MethodGet,
+				Path:             "/users/u1",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: &user{ID: "u1"},
+			},
+			check: func(tt *testing.T, test *Test, store *userStore) {
+				if !pending {
+					tt.Errorf("operation returned before the channel was released")
+				}
+			},
+		},
+		{
+			name: "with delay",
+			test: Test{
+				Operations:     []Operation{{Name: "Get", Args: Args{"u1"}, Delay: time.Second, WaitFor: release}},
+				Method:         http.MethodGet,
+				Path:           "/users/u1",
+				ExpectedStatus: http.StatusOK,
+			},
+			failure: "operation Get Delay is ignored with WaitFor",
+		},
+	})
+}
+
+func TestObserveMethod(t *testing.T) {
+	override := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m := r.Header.Get("X-HTTP-Method-Override"); r.Method == http.MethodPost && m != "" {
+				r.Method = m
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// the handler observes the test it runs for
+	cases := map[string]struct {
+		test    *Test
+		failure string
+	}{
+		"overridden": {
+			test: &Test{
+				Method:         http.MethodPost,
+				Path:           "/users/u1",
+				Setup:          func(r *http.Request) { r.Header.Set("X-HTTP-Method-Override", http.MethodDelete) },
+				ExpectedStatus: http.StatusNoContent,
+				ExpectedMethod: http.MethodDelete,
+			},
+		},
+		"mismatch": {
+			test: &Test{
+				Method:         http.MethodPost,
+				Path:           "/users/u1",
+				Setup:          func(r *http.Request) { r.Header.Set("X-HTTP-Method-Override", http.MethodDelete) },
+				ExpectedStatus: http.StatusNoContent,
+				ExpectedMethod: http.MethodPost,
+			},
+			failure: "unexpected method observed by handler",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			if c.failure == "" {
+				c.test.Do(&Mock{}, override(c.test.Observe(handler)), t)
+				return
+			}
+			requireFailure(t, run(c.test, &Mock{}, override(c.test.Observe(handler))), c.failure)
+		})
+	}
+}
+
+func TestSetupTest(t *testing.T) {
+	handler := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, _ := store.Get(r.Header.Get("X-User"))
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(u)
+		})
+	}
+
+	runCases(t, handler, []testCase{
+		{
+			name: "operation args",
+			test: Test{
+				Operations:       []Operation{{Name: "Get", Args: Args{"u42"}, Returns: Returns{&user{ID: "u42"}, nil}}},
+				Method:           http.MethodGet,
+				Path:             "/me",
+				Setup:            func(r *http.Request) { r.Header.Set("Accept", "application/json") },
+				SetupTest:        func(r *http.Request, t *Test) { r.Header.Set("X-User", t.Operations[0].Args[0].(string)) },
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: OperationReturn(0),
+			},
+			check: func(tt *testing.T, test *Test, store *userStore) {
+				if id := store.Calls[0].Arguments.String(0); id != "u42" {
+					tt.Errorf("backend called with %q, expected the header derived from the operation args", id)
+				}
+			},
+		},
+	})
+}
+
+func TestExpectedHeaderValues(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		w.Header().Add("Vary", "Origin")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "ordered",
+			test: Test{
+				Method:               http.MethodGet,
+				Path:                 "/",
+				ExpectedStatus:       http.StatusNoContent,
+				ExpectedHeaderValues: map[string][]string{"Vary": {"Accept-Encoding", "Origin"}},
+			},
+		},
+		{
+			name: "unordered",
+			test: Test{
+				Method:                http.MethodGet,
+				Path:                  "/",
+				ExpectedStatus:        http.StatusNoContent,
+				ExpectedHeaderValues:  map[string][]string{"Vary": {"Origin", "Accept-Encoding"}},
+				UnorderedHeaderValues: true,
+			},
+		},
+		{
+			name: "order",
+			test: Test{
+				Method:               http.MethodGet,
+				Path:                 "/",
+				ExpectedStatus:       http.StatusNoContent,
+				ExpectedHeaderValues: map[string][]string{"Vary": {"Origin", "Accept-Encoding"}},
+			},
+			failure: "header Vary",
+		},
+		{
+			name: "missing value",
+			test: Test{
+				Method:                http.MethodGet,
+				Path:                  "/",
+				ExpectedStatus:        http.StatusNoContent,
+				ExpectedHeaderValues:  map[string][]string{"Vary": {"Origin", "Accept-Encoding", "Cookie"}},
+				UnorderedHeaderValues: true,
+			},
+			failure: "header Vary",
+		},
+	})
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	handler := func(chunks int) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+
+			chunk := bytes.Repeat([]byte("x"), 512)
+			for i := 0; i < chunks; i++ {
+				if _, err := w.Write(chunk); err != nil {
+					return
+				}
+				w.(http.Flusher).Flush()
+			}
+		}))
+	}
+
+	test := Test{
+		Method:           http.MethodGet,
+		Path:             "/stream",
+		ExpectedStatus:   http.StatusOK,
+		MaxResponseBytes: 1024,
+	}
+
+	runCases(t, handler(2), []testCase{
+		{name: "within budget", test: test},
+		{name: "exceeded", test: test, handler: handler(1 << 12), failure: "response body exceeded 1024 bytes"},
+	})
+}
+
+func TestExpectedParsedQuery(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	setup := func(r *http.Request) { r.URL.RawQuery = "tag=a&tag=b%20c&q=%26x%3D1" }
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "decoded",
+			test: Test{
+				Method:              http.MethodGet,
+				Path:                "/search",
+				Setup:               setup,
+				ExpectedStatus:      http.StatusNoContent,
+				ExpectedParsedQuery: url.Values{"tag": {"a", "b c"}, "q": {"&x=1"}},
+			},
+		},
+		{
+			name: "mismatch",
+			test: Test{
+				Method:              http.MethodGet,
+				Path:                "/search",
+				Setup:               setup,
+				ExpectedStatus:      http.StatusNoContent,
+				ExpectedParsedQuery: url.Values{"tag": {"b c", "a"}, "q": {"&x=1"}},
+			},
+			failure: "unexpected query parsed by server",
+		},
+	})
+}
+
+func TestRequestTrailers(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"body":     string(body),
+			"encoding": r.TransferEncoding,
+			"checksum": r.Trailer.Get("X-Checksum"),
+		})
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "checksum",
+			test: Test{
+				Method:          http.MethodPost,
+				Path:            "/upload",
+				Request:         &user{ID: "u1"},
+				RequestTrailers: map[string]string{"X-Checksum": "abc123"},
+				ExpectedStatus:  http.StatusOK,
+				ExpectedResponse: map[string]interface{}{
+					"body":     `{"ID":"u1","Name":"","Address":{"City":""}}`,
+					"encoding": []string{"chunked"},
+					"checksum": "abc123",
+				},
+			},
+		},
+	})
+}
+
+func TestDoFunc(t *testing.T) {
+	store := &userStore{}
+
+	getUser := func(w http.ResponseWriter, r *http.Request) {
+		u, _ := store.Get(strings.TrimPrefix(r.URL.Path, "/users/"))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(u)
+	}
+
+	(&Test{
+		Operations:       []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}}},
+		Method:           http.MethodGet,
+		Path:             "/users/u1",
+		ExpectedStatus:   http.StatusOK,
+		ExpectedResponse: OperationReturn(0),
+	}).DoFunc(&store.Mock, getUser, t)
+}
+
+func TestSkipExpectations(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "skipped",
+			test: Test{
+				Operations:       []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{nil, nil}}},
+				Method:           http.MethodGet,
+				Path:             "/users/u1",
+				ExpectedStatus:   http.StatusNoContent,
+				SkipExpectations: true,
+			},
+		},
+		{
+			name: "strict",
+			test: Test{
+				Operations:     []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{nil, nil}}},
+				Method:         http.MethodGet,
+				Path:           "/users/u1",
+				ExpectedStatus: http.StatusNoContent,
+			},
+			failure: "0 out of 1 expectation(s) were met",
+		},
+	})
+}
+
+func TestContextValues(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := r.Context().Value(tenantKey{}).(string)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"tenant": tenant})
+	})
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "injected",
+			test: Test{
+				Method:           http.MethodGet,
+				Path:             "/tenant",
+				ContextValues:    map[interface{}]interface{}{tenantKey{}: "acme"},
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: map[string]string{"tenant": "acme"},
+			},
+		},
+		{
+			name: "missing",
+			test: Test{
+				Method:         http.MethodGet,
+				Path:           "/tenant",
+				ExpectedStatus: http.StatusUnauthorized,
+			},
+		},
+	})
+}
+
+func TestExpectedRequestContentLength(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	body := &user{ID: "u1", Name: "Ann"}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %s", err.Error())
+	}
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "known length",
+			test: Test{
+				Method:                       http.MethodPut,
+				Path:                         "/users/u1",
+				Request:                      body,
+				ExpectedStatus:               http.StatusNoContent,
+				ExpectedRequestContentLength: int64(len(data)),
+			},
+		},
+		{
+			name: "unknown length",
+			test: Test{
+				Method:                       http.MethodPut,
+				Path:                         "/users/u1",
+				Request:                      body,
+				ChunkedRequest:               true,
+				ExpectedStatus:               http.StatusNoContent,
+				ExpectedRequestContentLength: int64(len(data)),
+			},
+			failure: "unexpected request content length",
+		},
+	})
+}
+
+func TestOperationPanic(t *testing.T) {
+	handler := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if v := recover(); v != nil {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusInternalServerError)
+					json.NewEncoder(w).Encode(map[string]string{"error": "internal"})
+				}
+			}()
+
+			u, _ := store.Get("u1")
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(u)
+		})
+	}
+
+	runCases(t, handler, []testCase{
+		{
+			name: "recovered",
+			test: Test{
+				Operations:       []Operation{{Name: "Get", Args: Args{"u1"}, Panic: "connection reset"}},
+				Method:           http.MethodGet,
+				Path:             "/users/u1",
+				ExpectedStatus:   http.StatusInternalServerError,
+				ExpectedResponse: map[string]string{"error": "internal"},
+			},
+		},
+	})
+}
+
+func TestCancelAfter(t *testing.T) {
+	var stopped bool
+
+	handler := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			store.Fetch(r.Context(), "u1")
+
+			select {
+			case <-r.Context().Done():
+				stopped = true
+			case <-time.After(2 * time.Second):
+				w.WriteHeader(http.StatusOK)
+			}
+		})
+	}
+
+	c := CaptureContext()
+	start := time.Now()
+
+	runCases(t, handler, []testCase{
+		{
+			name: "canceled",
+			test: Test{
+				Operations:     []Operation{{Name: "Fetch", Args: Args{c.Matcher(), "u1"}, Returns: Returns{nil}}},
+				Method:         http.MethodGet,
+				Path:           "/users/u1/report",
+				CancelAfter:    50 * time.Millisecond,
+				ExpectedStatus: http.StatusOK,
+			},
+			check: func(tt *testing.T, test *Test, store *userStore) {
+				c.AssertCanceled(tt)
+
+				if !stopped {
+					tt.Errorf("handler did not stop on cancel")
+				}
+
+				if d := time.Since(start); d > time.Second {
+					tt.Errorf("request took %s after cancel", d)
+				}
+			},
+		},
+	})
+}
+
+func TestHandlerFactory(t *testing.T) {
+	var base string
+
+	runCases(t, stateless(nil), []testCase{
+		{
+			name: "server url",
+			test: Test{
+				Method:         http.MethodPost,
+				Path:           "/users",
+				ExpectedStatus: http.StatusCreated,
+				HandlerFactory: func(baseURL string) http.Handler {
+					base = baseURL
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						w.Header().Set("Location", baseURL+"/users/u1")
+						w.WriteHeader(http.StatusCreated)
+					})
+				},
+				ValidateResponse: func(t *Test, resp *http.Response, body []byte) error {
+					if l := resp.Header.Get("Location"); l != base+"/users/u1" {
+						return fmt.Errorf("location %q is not under the server url %s", l, base)
+					}
+					return nil
+				},
+			},
+			check: func(tt *testing.T, test *Test, store *userStore) {
+				if !strings.HasPrefix(base, "https://127.0.0.1:") {
+					tt.Errorf("factory called with %q, expected the test server url", base)
+				}
+			},
+		},
+	})
+}
+
+func TestExpectedTLSVersion(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	var cipher uint16
+
+	runCases(t, stateless(handler), []testCase{
+		{
+			name: "negotiated",
+			test: Test{
+				Method:             http.MethodGet,
+				Path:               "/",
+				ExpectedStatus:     http.StatusNoContent,
+				ExpectedTLSVersion: tls.VersionTLS12,
+				OnTLS:              func(state *tls.ConnectionState) { cipher = state.CipherSuite },
+			},
+			check: func(tt *testing.T, test *Test, store *userStore) {
+				if cipher == 0 {
+					tt.Errorf("OnTLS not called with the connection state")
+				}
+			},
+		},
+		{
+			name: "below minimum",
+			test: Test{
+				Method:             http.MethodGet,
+				Path:               "/",
+				ExpectedStatus:     http.StatusNoContent,
+				ExpectedTLSVersion: tls.VersionTLS13,
+				server:             server,
+			},
+			failure: "negotiated tls version 0x0303, expected at least 0x0304",
+		},
+	})
+}
+
+func TestExpectEmptyBody(t *testing.T) {
+	handler := func(status int, body string) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}))
+	}
+
+	runCases(t, handler(http.StatusNoContent, ""), []testCase{
+		{
+			name: "flag",
+			test: Test{Method: http.MethodDelete, Path: "/users/u1", ExpectedStatus: http.StatusNoContent, ExpectEmptyBody: true},
+		},
+		{
+			name:    "flag with body",
+			test:    Test{Method: http.MethodDelete, Path: "/users/u1", ExpectedStatus: http.StatusOK, ExpectEmptyBody: true},
+			handler: handler(http.StatusOK, "deleted"),
+			failure: "unexpected response body",
+		},
+		{
+			name: "empty string",
+			test: Test{Method: http.MethodDelete, Path: "/users/u1", ExpectedStatus: http.StatusNoContent, ExpectedResponse: ""},
+		},
+		{
+			name:    "empty string with body",
+			test:    Test{Method: http.MethodDelete, Path: "/users/u1", ExpectedStatus: http.StatusOK, ExpectedResponse: ""},
+			handler: handler(http.StatusOK, "deleted"),
+			failure: "unexpected response body",
+		},
+	})
+}
+
+func TestRequiredHeaders(t *testing.T) {
+	handler := func(id string) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if id != "" {
+				w.Header().Set("X-Request-ID", id)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	}
+
+	test := Test{
+		Method:          http.MethodGet,
+		Path:            "/",
+		ExpectedStatus:  http.StatusNoContent,
+		RequiredHeaders: []string{"X-Request-ID"},
+	}
+
+	runCases(t, handler("3f2a"), []testCase{
+		{name: "fixed value", test: test},
+		{name: "random value", test: test, handler: handler(strconv.Itoa(rand.Int()))},
+		{name: "missing", test: test, handler: handler(""), failure: "required response header X-Request-ID missing"},
+	})
+}
+
+func TestExpectedLocationQuery(t *testing.T) {
+	handler := func(location string) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, location, http.StatusFound)
+		}))
+	}
+
+	test := Test{
+		Method:         http.MethodGet,
+		Path:           "/account",
+		ExpectedStatus: http.StatusFound,
+		ExpectedLocationQuery: url.Values{
+			"return_to": []string{"/account"},
+		},
+	}
+
+	runCases(t, handler("/login?state=x9&return_to=%2Faccount"), []testCase{
+		{name: "preserved", test: test},
+		{name: "dropped", test: test, handler: handler("/login?state=x9"), failure: "unexpected location query parameter return_to"},
+		{name: "changed", test: test, handler: handler("/login?return_to=%2F"), failure: "unexpected location query parameter return_to"},
+	})
+}
+
+func TestExpectedResponseFunc(t *testing.T) {
+	handler := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u := &user{ID: strconv.Itoa(rand.Int()), Name: "ada"}
+			if err := store.Save(u); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"id": u.ID})
+		})
+	}
+
+	var saved *user
+
+	ops := []Operation{
+		{
+			Name: "Save",
+			Args: Args{mock.MatchedBy(func(u *user) bool {
+				saved = u
+				return true
+			})},
+			Returns: Returns{nil},
+		},
+	}
+
+	runCases(t, handler, []testCase{
+		{
+			name: "saved id",
+			test: Test{
+				Operations:     ops,
+				Method:         http.MethodPost,
+				Path:           "/users",
+				ExpectedStatus: http.StatusOK,
+				ExpectedResponse: func() interface{} {
+					return map[string]string{"id": saved.ID}
+				},
+			},
+		},
+		{
+			name: "other id",
+			test: Test{
+				Operations:     ops,
+				Method:         http.MethodPost,
+				Path:           "/users",
+				ExpectedStatus: http.StatusOK,
+				ExpectedResponse: func() interface{} {
+					return map[string]string{"id": "u0"}
+				},
+			},
+			failure: `$.id: expected "u0"`,
+		},
+	})
+}
+
+func TestFollowRedirectsMethod(t *testing.T) {
+	mux := http.NewServeMux()
+	for path, status := range map[string]int{
+		"/307": http.StatusTemporaryRedirect,
+		"/308": http.StatusPermanentRedirect,
+		"/303": http.StatusSeeOther,
+	} {
+		status := status
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/users", status)
+		})
+	}
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"method": r.Method, "body": string(body)})
+	})
+
+	final := func(tt *testing.T, test *Test, store *userStore) {
+		if test.FinalRequest.URL.Path != "/users" {
+			tt.Fatalf("final request path %s, expected /users", test.FinalRequest.URL.Path)
+		}
+	}
+
+	runCases(t, stateless(mux), []testCase{
+		{
+			name: "307",
+			test: Test{
+				Method:              http.MethodPost,
+				Path:                "/307",
+				Request:             map[string]string{"Name": "ada"},
+				ExpectedStatus:      http.StatusOK,
+				FollowRedirects:     true,
+				ExpectedFinalMethod: http.MethodPost,
+				ExpectedResponse:    map[string]string{"method": http.MethodPost, "body": `{"Name":"ada"}`},
+			},
+			check: final,
+		},
+		{
+			name: "308",
+			test: Test{
+				Method:              http.MethodPost,
+				Path:                "/308",
+				Request:             map[string]string{"Name": "ada"},
+				ExpectedStatus:      http.StatusOK,
+				FollowRedirects:     true,
+				ExpectedFinalMethod: http.MethodPost,
+				ExpectedResponse:    map[string]string{"method": http.MethodPost, "body": `{"Name":"ada"}`},
+			},
+			check: final,
+		},
+		{
+			name: "303",
+			test: Test{
+				Method:              http.MethodPost,
+				Path:                "/303",
+				Request:             map[string]string{"Name": "ada"},
+				ExpectedStatus:      http.StatusOK,
+				FollowRedirects:     true,
+				ExpectedFinalMethod: http.MethodGet,
+				ExpectedResponse:    map[string]string{"method": http.MethodGet, "body": ""},
+			},
+		},
+		{
+			name: "downgraded",
+			test: Test{
+				Method:              http.MethodPost,
+				Path:                "/303",
+				Request:             map[string]string{"Name": "ada"},
+				ExpectedStatus:      http.StatusOK,
+				FollowRedirects:     true,
+				ExpectedFinalMethod: http.MethodPost,
+				ExpectedResponse:    map[string]string{"method": http.MethodPost, "body": `{"Name":"ada"}`},
+			},
+			failure: "unexpected final request method",
+		},
+	})
+}
+
+func TestDoAgainst(t *testing.T) {
+	var store *userStore
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, err := store.Get(strings.TrimPrefix(r.URL.Path, "/users/"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(u)
+	}))
+
+	var conns int32
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+
+	server.StartTLS()
+	defer server.Close()
+
+	cases := []testCase{
+		{
+			name: "found",
+			test: Test{
+				Operations:       []Operation{{Name: "Get", Args: Args{"1"}, Returns: Returns{&user{ID: "1", Name: "ada"}, nil}}},
+				Method:           http.MethodGet,
+				Path:             "/users/1",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: &user{ID: "1", Name: "ada"},
+			},
+		},
+		{
+			name: "not found",
+			test: Test{
+				Operations:     []Operation{{Name: "Get", Args: Args{"2"}, Returns: Returns{nil, errors.New("not found")}}},
+				Method:         http.MethodGet,
+				Path:           "/users/2",
+				ExpectedStatus: http.StatusNotFound,
+			},
+		},
+		{
+			name: "other",
+			test: Test{
+				Operations:       []Operation{{Name: "Get", Args: Args{"3"}, Returns: Returns{&user{ID: "3", Name: "grace"}, nil}}},
+				Method:           http.MethodGet,
+				Path:             "/users/3",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: &user{ID: "3", Name: "grace"},
+			},
+		},
+		{
+			name: "unexpected status",
+			test: Test{
+				Operations:     []Operation{{Name: "Get", Args: Args{"1"}, Returns: Returns{&user{ID: "1"}, nil}}},
+				Method:         http.MethodGet,
+				Path:           "/users/1",
+				ExpectedStatus: http.StatusNotFound,
+			},
+			failure: "actual  : 200",
+		},
+		{
+			name: "cancel after",
+			test: Test{
+				Operations:     []Operation{{Name: "Get", Args: Args{"1"}, Returns: Returns{&user{ID: "1"}, nil}}},
+				Method:         http.MethodGet,
+				Path:           "/users/1",
+				CancelAfter:    time.Millisecond,
+				ExpectedStatus: http.StatusOK,
+			},
+			failure: "CancelAfter observes the request in the test server, unavailable against an existing server",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			store = &userStore{}
+			test := c.test
+
+			if c.failure == "" {
+				test.DoAgainst(server, &store.Mock, t)
+				return
+			}
+
+			test.server = server
+			requireFailure(t, run(&test, &store.Mock, nil), c.failure)
+		})
+	}
+
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("server accepted %d connections, expected the client connection to be reused", n)
+	}
+}
+
+func TestReturnMap(t *testing.T) {
+	handler := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			names := []string{}
+			for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+				u, err := store.Get(id)
+				if err != nil {
+					names = append(names, err.Error())
+					continue
+				}
+				names = append(names, u.Name)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(names)
+		})
+	}
+
+	runCases(t, handler, []testCase{
+		{
+			name: "keyed",
+			test: Test{
+				Operations: []Operation{
+					{
+						Name:    "Get",
+						Args:    Args{"a"},
+						Returns: Returns{nil, errNotFound},
+						ReturnMap: map[string][]interface{}{
+							ArgKey("a"): {&user{ID: "a", Name: "ada"}, nil},
+							ArgKey("b"): {&user{ID: "b", Name: "grace"}, nil},
+						},
+					},
+				},
+				Method:           http.MethodGet,
+				Path:             "/users",
+				Query:            url.Values{"ids": []string{"a,b,a,c"}},
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: []string{"ada", "grace", "ada", "not found"},
+			},
+		},
+		{
+			name: "unkeyed",
+			test: Test{
+				Operations:       []Operation{{Name: "Get", Args: Args{"a"}, Returns: Returns{nil, errNotFound}}},
+				Method:           http.MethodGet,
+				Path:             "/users",
+				Query:            url.Values{"ids": []string{"a,b,a,c"}},
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: []string{"ada", "grace", "ada", "not found"},
+			},
+			failure: `$[0]: expected "ada", got "not found"`,
+		},
+	})
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	handler := func(body string) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}))
+	}
+
+	runCases(t, nil, []testCase{
+		{
+			name:    "value",
+			test:    Test{Method: http.MethodGet, Path: "/users/1", ExpectedStatus: http.StatusOK, DisallowUnknownFields: user{}},
+			handler: handler(`{"ID":"1","Name":"ada","Address":{"City":"London"}}`),
+		},
+		{
+			name:    "pointer",
+			test:    Test{Method: http.MethodGet, Path: "/users/1", ExpectedStatus: http.StatusOK, DisallowUnknownFields: &user{}},
+			handler: handler(`{"ID":"1"}`),
+		},
+		{
+			name:    "extra field",
+			test:    Test{Method: http.MethodGet, Path: "/users/1", ExpectedStatus: http.StatusOK, DisallowUnknownFields: user{}},
+			handler: handler(`{"ID":"1","Name":"ada","Email":"ada@example.com"}`),
+			failure: `response does not conform to litmus.user: json: unknown field "Email"`,
+		},
+		{
+			name:    "nested extra field",
+			test:    Test{Method: http.MethodGet, Path: "/users/1", ExpectedStatus: http.StatusOK, DisallowUnknownFields: &user{}},
+			handler: handler(`{"ID":"1","Address":{"City":"London","Zip":"N1"}}`),
+			failure: `response does not conform to litmus.user: json: unknown field "Zip"`,
+		},
+		{
+			name:    "type mismatch",
+			test:    Test{Method: http.MethodGet, Path: "/users/1", ExpectedStatus: http.StatusOK, DisallowUnknownFields: user{}},
+			handler: handler(`{"ID":1}`),
+			failure: "response does not conform to litmus.user: json: cannot unmarshal number",
+		},
+	})
+}
+
+func TestExpectedResponses(t *testing.T) {
+	handler := func(maxName int) func(*userStore) http.Handler {
+		return stateless(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var u user
+			if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+
+			if len(u.Name) > maxName {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "name too long"})
+				return
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(u)
+		}))
+	}
+
+	created := user{ID: "u1", Name: "abcdefgh"}
+
+	unchanged := func(tt *testing.T, test *Test, store *userStore) {
+		if test.ExpectedResponse != nil {
+			tt.Fatalf("test changed, ExpectedResponse %v", test.ExpectedResponse)
+		}
+	}
+
+	runCases(t, handler(8), []testCase{
+		{
+			name: "created",
+			test: Test{
+				Method:  http.MethodPost,
+				Path:    "/users",
+				Request: created,
+				ExpectedResponses: map[int]interface{}{
+					http.StatusCreated:    created,
+					http.StatusBadRequest: map[string]string{"error": "name too long"},
+				},
+			},
+			check: unchanged,
+		},
+		{
+			name: "rejected",
+			test: Test{
+				Method:  http.MethodPost,
+				Path:    "/users",
+				Request: created,
+				ExpectedResponses: map[int]interface{}{
+					http.StatusCreated:    created,
+					http.StatusBadRequest: map[string]string{"error": "name too long"},
+				},
+			},
+			handler: handler(7),
+			check:   unchanged,
+		},
+		{
+			name: "wrong branch body",
+			test: Test{
+				Method:  http.MethodPost,
+				Path:    "/users",
+				Request: created,
+				ExpectedResponses: map[int]interface{}{
+					http.StatusCreated:    created,
+					http.StatusBadRequest: map[string]string{"error": "name required"},
+				},
+			},
+			handler: handler(7),
+			failure: `$.error: expected "name required", got "name too long"`,
+		},
+		{
+			name: "undocumented status",
+			test: Test{
+				Method:            http.MethodPost,
+				Path:              "/users",
+				Request:           created,
+				ExpectedResponses: map[int]interface{}{http.StatusCreated: created},
+			},
+			handler: handler(7),
+			failure: "unexpected status 400, expected one of [201]",
+		},
+	})
+}
+
+func TestDefaultReturns(t *testing.T) {
+	handler := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			store.Audit("get u1")
+
+			u, err := store.Get("u1")
+			if err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(u)
+		})
+	}
+
+	twice := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			store.Get("u1")
+			handler(store).ServeHTTP(w, r)
+		})
+	}
+
+	var panicked string
+
+	// undeclared fails on the Audit call not covered by an operation or a default
+	undeclared := Test{
+		Method:         http.MethodGet,
+		Path:           "/users/u1",
+		ExpectedStatus: http.StatusInternalServerError,
+		ExpectPanic:    true,
+		OnPanic:        func(v interface{}) { panicked = fmt.Sprint(v) },
+	}
+
+	audited := func(tt *testing.T, test *Test, store *userStore) {
+		if !strings.Contains(panicked, "Audit(string)") {
+			tt.Errorf("unexpected panic %q, expected the undeclared Audit call", panicked)
+		}
+	}
+
+	runCases(t, handler, []testCase{
+		{
+			name: "fallback",
+			test: Test{
+				Operations:       []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}}},
+				DefaultReturns:   map[string][]interface{}{"Audit": {}, "Save": {nil}},
+				Method:           http.MethodGet,
+				Path:             "/users/u1",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: OperationReturn(0),
+			},
+		},
+		{
+			name:  "undeclared",
+			test:  undeclared,
+			check: audited,
+		},
+		{
+			name: "removed after the test",
+			test: Test{
+				Operations:       []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}}},
+				DefaultReturns:   map[string][]interface{}{"Audit": {}},
+				Method:           http.MethodGet,
+				Path:             "/users/u1",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: OperationReturn(0),
+			},
+			check: func(tt *testing.T, test *Test, store *userStore) {
+				panicked = ""
+
+				next := undeclared
+				next.Do(&store.Mock, handler(store), tt)
+
+				audited(tt, &next, store)
+			},
+		},
+		{
+			name: "declared operation",
+			test: Test{
+				Operations:       []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}, Times: 1}},
+				DefaultReturns:   map[string][]interface{}{"Audit": {}, "Get": {nil, nil}},
+				Method:           http.MethodGet,
+				Path:             "/users/u1",
+				ExpectedStatus:   http.StatusOK,
+				ExpectedResponse: OperationReturn(0),
+			},
+			handler: twice,
+			failure: "Get",
+		},
+	})
+
+	failures := run(&Test{
+		DefaultReturns: map[string][]interface{}{"Audit": {}},
+		Method:         http.MethodGet,
+		Path:           "/users/u1",
+		ExpectedStatus: http.StatusOK,
+	}, &wrappedStore{}, http.NotFoundHandler())
+	requireFailure(t, failures, "DefaultReturns requires a backend embedding Mock, got *litmus.wrappedStore")
+}
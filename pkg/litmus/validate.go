@@ -0,0 +1,176 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+)
+
+type (
+	// MarshalError is returned when a test field value cannot be marshalled
+	MarshalError struct {
+		// Field is the test field
+		Field string
+
+		// Type is the type of the value
+		Type reflect.Type
+
+		// Err is the marshal error
+		Err error
+	}
+)
+
+// Error implements the error interface
+func (e *MarshalError) Error() string {
+	return fmt.Sprintf("failed to marshal %s of type %s: %s", e.Field, e.Type, e.Err.Error())
+}
+
+// Unwrap returns the marshal error
+func (e *MarshalError) Unwrap() error {
+	return e.Err
+}
+
+func marshalError(field string, v interface{}, err error) *MarshalError {
+	return &MarshalError{
+		Field: field,
+		Type:  reflect.TypeOf(v),
+		Err:   err,
+	}
+}
+
+// Validate performs static checks of the test definition without executing it
+func (t *Test) Validate() error {
+	if t.Method == "" && t.RequestObject == nil {
+		return fmt.Errorf("method is required")
+	}
+
+	if t.StrictBackend != nil {
+		typ := reflect.TypeOf(t.StrictBackend)
+		for _, o := range t.Operations {
+			if _, ok := typ.MethodByName(o.Name); !ok {
+				return fmt.Errorf("unknown backend method %s on %s", o.Name, typ)
+			}
+		}
+	}
+
+	for _, o := range t.Operations {
+		if t.MaxRetries > 0 && o.Times > 0 {
+			return fmt.Errorf("operation %s Times is exact, retried requests may call it again, use MaxTimes", o.Name)
+		}
+
+		if o.Delay > 0 && o.WaitFor != nil {
+			return fmt.Errorf("operation %s Delay is ignored with WaitFor", o.Name)
+		}
+	}
+
+	if ref, ok := t.Request.(*OperationRef); ok {
+		if err := t.validateRef(ref, false); err != nil {
+			return fmt.Errorf("Request: %w", err)
+		}
+	}
+
+	if ref, ok := t.ExpectedResponse.(*OperationRef); ok {
+		if err := t.validateRef(ref, true); err != nil {
+			return fmt.Errorf("ExpectedResponse: %w", err)
+		}
+	}
+
+	switch m := t.Request.(type) {
+	case nil, []byte, string, chan []byte, <-chan []byte, *OperationRef, RequestHandler:
+	default:
+		if _, err := json.Marshal(m); err != nil {
+			return marshalError("Request", m, err)
+		}
+	}
+
+	if len(t.ExpectedResponses) > 0 && t.ExpectedResponse != nil {
+		return fmt.Errorf("ExpectedResponse set with ExpectedResponses")
+	}
+
+	if t.server != nil {
+		if f := t.serverField(); f != "" {
+			return fmt.Errorf("%s observes the request in the test server, unavailable against an existing server", f)
+		}
+	}
+
+	if t.NoRequestBody && t.Request != nil {
+		return fmt.Errorf("request body set for %s with NoRequestBody", t.Method)
+	}
+
+	if (t.Method == http.MethodGet || t.Method == http.MethodHead) && t.Request != nil && !t.AllowRequestBody {
+		return fmt.Errorf("request body set for %s without AllowRequestBody", t.Method)
+	}
+
+	if t.ExpectedLog != "" && t.LogOutput == nil {
+		return fmt.Errorf("ExpectedLog requires LogOutput")
+	}
+
+	for _, ct := range append([]string{t.RequestContentType, t.ExpectedContentType}, t.ExpectedContentTypes...) {
+		if ct == "" {
+			continue
+		}
+		if _, _, err := mime.ParseMediaType(ct); err != nil {
+			return fmt.Errorf("invalid content type %q: %w", ct, err)
+		}
+	}
+
+	return nil
+}
+
+// serverField returns the first set field that requires the test server wrapping the handler
+func (t *Test) serverField() string {
+	switch {
+	case t.NoRequestBody:
+		return "NoRequestBody"
+	case t.ExpectedRequestContentLength != 0:
+		return "ExpectedRequestContentLength"
+	case t.ExpectedParsedQuery != nil:
+		return "ExpectedParsedQuery"
+	case t.ExpectPanic:
+		return "ExpectPanic"
+	case t.OnPanic != nil:
+		return "OnPanic"
+	case len(t.AssertChanged) > 0:
+		return "AssertChanged"
+	case len(t.AssertUnchanged) > 0:
+		return "AssertUnchanged"
+	case len(t.ContextValues) > 0:
+		return "ContextValues"
+	case t.HandlerFactory != nil:
+		return "HandlerFactory"
+	case len(t.FlushAfter) > 0:
+		return "FlushAfter"
+	case t.CancelAfter > 0:
+		return "CancelAfter"
+	}
+	return ""
+}
+
+// validateRef checks the reference points at an existing operation arg or return
+func (t *Test) validateRef(ref *OperationRef, isReturn bool) error {
+	if ref.Index < 0 || ref.Index >= len(t.Operations) {
+		return fmt.Errorf("operation index %d out of range [0:%d]", ref.Index, len(t.Operations))
+	}
+
+	op := t.Operations[ref.Index]
+
+	if isReturn {
+		if ref.Return < 0 || ref.Return >= len(op.Returns) {
+			return fmt.Errorf("operation %s return index %d out of range [0:%d]", op.Name, ref.Return, len(op.Returns))
+		}
+	} else if ref.Arg < 0 || ref.Arg >= len(op.Args) {
+		return fmt.Errorf("operation %s arg index %d out of range [0:%d]", op.Name, ref.Arg, len(op.Args))
+	}
+
+	return nil
+}
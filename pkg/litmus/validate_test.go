@@ -0,0 +1,136 @@
+/*
+ * Copyright (C) 2020 Atomic Media Foundation
+ *
+ * This software may be modified and distributed under the terms
+ * of the MIT license.  See the LICENSE file in the root of this
+ * workspace for details.
+ */
+
+package litmus
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStrictBackend(t *testing.T) {
+	handler := func(store *userStore) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			store.Get("1")
+		})
+	}
+
+	runCases(t, handler, []testCase{
+		{
+			name: "known method",
+			test: Test{
+				StrictBackend:  &userStore{},
+				Operations:     []Operation{{Name: "Get", Args: Args{"1"}, Returns: Returns{&user{ID: "1"}, nil}}},
+				Method:         http.MethodGet,
+				Path:           "/users/1",
+				ExpectedStatus: http.StatusOK,
+			},
+		},
+		{
+			name: "unknown method",
+			test: Test{
+				StrictBackend:  &userStore{},
+				Operations:     []Operation{{Name: "Gte", Args: Args{"1"}, Returns: Returns{&user{ID: "1"}, nil}}},
+				Method:         http.MethodGet,
+				Path:           "/users/1",
+				ExpectedStatus: http.StatusOK,
+			},
+			failure: "unknown backend method Gte on *litmus.userStore",
+		},
+	})
+}
+
+func TestValidate(t *testing.T) {
+	var called bool
+
+	handler := func(store *userStore) http.Handler {
+		// each case starts with the handler not called
+		called = false
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+
+			u, _ := store.Get("u1")
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(u)
+		})
+	}
+
+	get := func(ref *OperationRef) Test {
+		return Test{
+			Operations:       []Operation{{Name: "Get", Args: Args{"u1"}, Returns: Returns{&user{ID: "u1"}, nil}}},
+			Method:           http.MethodGet,
+			Path:             "/users/u1",
+			ExpectedStatus:   http.StatusOK,
+			ExpectedResponse: ref,
+		}
+	}
+
+	runCases(t, handler, []testCase{
+		{
+			name: "valid",
+			test: get(OperationReturn(0)),
+			check: func(tt *testing.T, test *Test, store *userStore) {
+				if err := test.Validate(); err != nil {
+					tt.Fatalf("failed to validate test: %s", err.Error())
+				}
+			},
+		},
+		{
+			name:    "out of range",
+			test:    get(OperationReturn(2)),
+			failure: "invalid test: ",
+			check: func(tt *testing.T, test *Test, store *userStore) {
+				err := test.Validate()
+				if err == nil || !strings.Contains(err.Error(), "operation Get return index 2 out of range [0:2]") {
+					tt.Fatalf("unexpected validation error %v", err)
+				}
+
+				if called {
+					tt.Errorf("handler called for an invalid test")
+				}
+			},
+		},
+	})
+}
+
+func TestMarshalError(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	test := &Test{
+		Method:         http.MethodPost,
+		Path:           "/users",
+		Request:        map[string]interface{}{"callback": func() {}},
+		ExpectedStatus: http.StatusNoContent,
+	}
+
+	err := test.Validate()
+
+	var merr *MarshalError
+	if !errors.As(err, &merr) {
+		t.Fatalf("unexpected validation error %v", err)
+	}
+
+	if merr.Field != "Request" || merr.Type.String() != "map[string]interface {}" {
+		t.Errorf("unexpected marshal error field %s type %s", merr.Field, merr.Type)
+	}
+
+	var jerr *json.UnsupportedTypeError
+	if !errors.As(err, &jerr) {
+		t.Errorf("marshal error does not wrap the json error: %v", err)
+	}
+
+	failures := run(test, &Mock{}, handler)
+	requireFailure(t, failures, "failed to marshal Request of type map[string]interface {}: json: unsupported type: func()")
+}